@@ -0,0 +1,140 @@
+package oteltrace
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// attributeDecoder restores the types of the attributes otel2influx flattens
+// into JSON and line protocol. Numbers without a fraction or exponent are
+// ints again, arrays holding any fractional number are arrays of doubles, and
+// the string values of the bytes keys are decoded from base64, as
+// encoding/json writes bytes. A double with an integral value, e.g. 2.0, is
+// written by otel2influx as 2 and so comes back as an int.
+type attributeDecoder struct {
+	bytesKeys map[string]bool
+}
+
+func newAttributeDecoder(bytesKeys []string) attributeDecoder {
+	d := attributeDecoder{bytesKeys: make(map[string]bool, len(bytesKeys))}
+	for _, key := range bytesKeys {
+		d.bytesKeys[key] = true
+	}
+	return d
+}
+
+// decode parses the attributes field, keeping numbers as json.Number so that
+// put can tell ints from doubles.
+func (d attributeDecoder) decode(raw string) (map[string]any, error) {
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	decoder.UseNumber()
+	var attributes map[string]any
+	if err := decoder.Decode(&attributes); err != nil {
+		return nil, err
+	}
+	return attributes, nil
+}
+
+// decodeInto decodes the attributes field into attributes.
+func (d attributeDecoder) decodeInto(raw string, attributes pcommon.Map) error {
+	decoded, err := d.decode(raw)
+	if err != nil {
+		return err
+	}
+	for key, value := range decoded {
+		d.put(attributes, key, value)
+	}
+	return nil
+}
+
+// put sets key to value, either decoded from JSON or a field value.
+func (d attributeDecoder) put(attributes pcommon.Map, key string, value any) {
+	if s, ok := value.(string); ok && d.bytesKeys[key] {
+		if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+			attributes.PutEmptyBytes(key).FromRaw(b)
+			return
+		}
+	}
+	setValue(attributes.PutEmpty(key), value)
+}
+
+// putTag sets key to the value of a tag, which otel2influx writes with the
+// attribute's value as a string. Tags carry no type, so a value is only
+// decoded when it's exactly how an int, double or bool attribute is written;
+// anything else, such as "1.10" or "00123", stays the string it was.
+func (d attributeDecoder) putTag(attributes pcommon.Map, key, value string) {
+	if d.bytesKeys[key] {
+		d.put(attributes, key, value)
+		return
+	}
+	if b, err := strconv.ParseBool(value); err == nil && strconv.FormatBool(b) == value {
+		attributes.PutBool(key, b)
+	} else if i, err := strconv.ParseInt(value, 10, 64); err == nil && strconv.FormatInt(i, 10) == value {
+		attributes.PutInt(key, i)
+	} else if f, err := strconv.ParseFloat(value, 64); err == nil && pcommon.NewValueDouble(f).AsString() == value {
+		attributes.PutDouble(key, f)
+	} else {
+		attributes.PutStr(key, value)
+	}
+}
+
+func setValue(dest pcommon.Value, value any) {
+	switch v := value.(type) {
+	case nil:
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			dest.SetInt(i)
+		} else if f, err := v.Float64(); err == nil {
+			dest.SetDouble(f)
+		} else {
+			dest.SetStr(v.String())
+		}
+	case string:
+		dest.SetStr(v)
+	case bool:
+		dest.SetBool(v)
+	case int64:
+		dest.SetInt(v)
+	case uint64:
+		if v > math.MaxInt64 {
+			dest.SetDouble(float64(v))
+		} else {
+			dest.SetInt(int64(v))
+		}
+	case float64:
+		dest.SetDouble(v)
+	case []any:
+		// OTLP arrays hold a single type, so one fractional number makes
+		// all of them doubles
+		doubles := false
+		for _, e := range v {
+			if n, ok := e.(json.Number); ok {
+				if _, err := n.Int64(); err != nil {
+					doubles = true
+				}
+			}
+		}
+		slice := dest.SetEmptySlice()
+		for _, e := range v {
+			if n, ok := e.(json.Number); ok && doubles {
+				f, _ := n.Float64()
+				slice.AppendEmpty().SetDouble(f)
+				continue
+			}
+			setValue(slice.AppendEmpty(), e)
+		}
+	case map[string]any:
+		m := dest.SetEmptyMap()
+		for key, e := range v {
+			setValue(m.PutEmpty(key), e)
+		}
+	default:
+		dest.SetStr(fmt.Sprint(v))
+	}
+}
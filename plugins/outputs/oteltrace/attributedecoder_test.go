@@ -0,0 +1,75 @@
+package oteltrace_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/catherinetcai/telegraf-execd-otel/plugins/outputs/oteltrace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestOtelTraceTypedAttributes(t *testing.T) {
+	td := ptrace.NewTraces()
+	span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetTraceID(pcommon.TraceID{1})
+	span.SetSpanID(pcommon.SpanID{2})
+	span.SetName("pay")
+	span.SetStartTimestamp(pcommon.NewTimestampFromTime(time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)))
+	require.NoError(t, span.Attributes().FromRaw(map[string]any{
+		"http.status_code": 200,
+		"http.duration":    1.5,
+		"cache.hit":        true,
+		"user.name":        "jo",
+		"request.id":       []byte{0xde, 0xad, 0xbe, 0xef},
+		"retry.delays":     []any{1, 2, 3},
+		"weights":          []any{0.5, 2.0},
+		"peer":             map[string]any{"port": 8080, "tls": false},
+		"big":              int64(1) << 60,
+	}))
+
+	ot := &oteltrace.OtelTrace{BytesAttributes: []string{"request.id"}}
+	exported := writeTraces(t, ot, otel2influxMetrics(t, td)...)
+	require.Equal(t, 1, exported.SpanCount())
+	got := exported.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0)
+	assert.Equal(t, map[string]any{
+		"http.status_code": int64(200),
+		"http.duration":    1.5,
+		"cache.hit":        true,
+		"user.name":        "jo",
+		"request.id":       []byte{0xde, 0xad, 0xbe, 0xef},
+		"retry.delays":     []any{int64(1), int64(2), int64(3)},
+		"weights":          []any{0.5, 2.0},
+		"peer":             map[string]any{"port": int64(8080), "tls": false},
+		"big":              int64(1) << 60,
+	}, got.Attributes().AsRaw())
+}
+
+func TestOtelTraceTypedTagAttributes(t *testing.T) {
+	record := spanEventRows(t, spanEvent{"retry", time.Millisecond, nil})[0]
+	record.AddTag("http.status_code", "503")
+	record.AddTag("retry.backoff", "1.5")
+	record.AddTag("retry.final", "false")
+	record.AddTag("retry.zone", "eu-1")
+	// Values that wouldn't be written the same way again stay strings
+	record.AddTag("app.version", "1.10")
+	record.AddTag("zip", "00123")
+	record.AddTag("cached", "TRUE")
+	record.AddTag("ratio", "1e3")
+	td := writeTraces(t, &oteltrace.OtelTrace{LogSpanEvents: true}, generateTraceAsMetric(), record)
+	require.Equal(t, 1, td.SpanCount())
+	events := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Events()
+	require.Equal(t, 1, events.Len())
+	assert.Equal(t, map[string]any{
+		"http.status_code": int64(503),
+		"retry.backoff":    1.5,
+		"retry.final":      false,
+		"retry.zone":       "eu-1",
+		"app.version":      "1.10",
+		"zip":              "00123",
+		"cached":           "TRUE",
+		"ratio":            "1e3",
+	}, events.At(0).Attributes().AsRaw())
+}
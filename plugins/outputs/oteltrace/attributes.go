@@ -0,0 +1,323 @@
+package oteltrace
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	semconv "go.opentelemetry.io/collector/semconv/v1.16.0"
+)
+
+// Attribute contexts an AttributesProcessor can apply its actions to.
+const (
+	attributeContextResource = "resource"
+	attributeContextSpan     = "span"
+	attributeContextEvent    = "event"
+	attributeContextLink     = "link"
+)
+
+// Attribute actions, with the semantics of the collector's attributes
+// processor.
+const (
+	attributeActionInsert  = "insert"
+	attributeActionUpdate  = "update"
+	attributeActionUpsert  = "upsert"
+	attributeActionDelete  = "delete"
+	attributeActionHash    = "hash"
+	attributeActionExtract = "extract"
+)
+
+const (
+	matchTypeStrict = "strict"
+	matchTypeRegexp = "regexp"
+)
+
+// AttributesProcessor applies an ordered list of actions to the attributes of
+// the spans that match Include and don't match Exclude.
+type AttributesProcessor struct {
+	Contexts []string           `toml:"contexts"`
+	Include  *SpanMatch         `toml:"include"`
+	Exclude  *SpanMatch         `toml:"exclude"`
+	Actions  []*AttributeAction `toml:"actions"`
+}
+
+// SpanMatch selects spans by their service.name, name, kind and attributes.
+// A span matches when every property that's set matches; lists match when
+// any of their items does.
+type SpanMatch struct {
+	MatchType  string            `toml:"match_type"`
+	Services   []string          `toml:"services"`
+	SpanNames  []string          `toml:"span_names"`
+	SpanKinds  []string          `toml:"span_kinds"`
+	Attributes map[string]string `toml:"attributes"`
+
+	services   []*regexp.Regexp
+	spanNames  []*regexp.Regexp
+	spanKinds  []ptrace.SpanKind
+	attributes map[string]*regexp.Regexp
+}
+
+// AttributeAction is a single change to the attributes named Key, or whose
+// keys match Pattern for delete and hash. The value comes from Value or from
+// the attribute FromAttribute. Extract applies the regular expression Pattern
+// to Key's value and upserts its named groups as attributes.
+type AttributeAction struct {
+	Key           string      `toml:"key"`
+	Action        string      `toml:"action"`
+	Value         interface{} `toml:"value"`
+	FromAttribute string      `toml:"from_attribute"`
+	Pattern       string      `toml:"pattern"`
+
+	pattern *regexp.Regexp
+}
+
+func (p *AttributesProcessor) init() error {
+	if len(p.Actions) == 0 {
+		return fmt.Errorf("attributes processor needs at least one action")
+	}
+	if len(p.Contexts) == 0 {
+		p.Contexts = []string{attributeContextSpan}
+	}
+	for _, context := range p.Contexts {
+		switch context {
+		case attributeContextResource, attributeContextSpan, attributeContextEvent, attributeContextLink:
+		default:
+			return fmt.Errorf("invalid attributes context %q", context)
+		}
+	}
+	for _, match := range []*SpanMatch{p.Include, p.Exclude} {
+		if match == nil {
+			continue
+		}
+		if err := match.init(); err != nil {
+			return err
+		}
+	}
+	for _, action := range p.Actions {
+		if err := action.init(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *SpanMatch) init() error {
+	if m.MatchType == "" {
+		m.MatchType = matchTypeStrict
+	}
+	if m.MatchType != matchTypeStrict && m.MatchType != matchTypeRegexp {
+		return fmt.Errorf("invalid match_type %q", m.MatchType)
+	}
+	var err error
+	if m.services, err = m.compile(m.Services); err != nil {
+		return err
+	}
+	if m.spanNames, err = m.compile(m.SpanNames); err != nil {
+		return err
+	}
+	for _, kind := range m.SpanKinds {
+		spanKind, err := spanKindFromConfig(kind)
+		if err != nil {
+			return err
+		}
+		m.spanKinds = append(m.spanKinds, spanKind)
+	}
+	m.attributes = make(map[string]*regexp.Regexp, len(m.Attributes))
+	for key, value := range m.Attributes {
+		re, err := m.compile([]string{value})
+		if err != nil {
+			return err
+		}
+		m.attributes[key] = re[0]
+	}
+	return nil
+}
+
+// compile turns values into regular expressions, anchored literals for the
+// strict match type.
+func (m *SpanMatch) compile(values []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(values))
+	for _, value := range values {
+		if m.MatchType == matchTypeStrict {
+			value = "^" + regexp.QuoteMeta(value) + "$"
+		}
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", value, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+func (m *SpanMatch) matches(resource pcommon.Resource, span ptrace.Span) bool {
+	if len(m.services) > 0 {
+		serviceName := ""
+		if v, ok := resource.Attributes().Get(semconv.AttributeServiceName); ok {
+			serviceName = v.AsString()
+		}
+		if !matchesAny(m.services, serviceName) {
+			return false
+		}
+	}
+	if len(m.spanNames) > 0 && !matchesAny(m.spanNames, span.Name()) {
+		return false
+	}
+	if len(m.spanKinds) > 0 && !slices.Contains(m.spanKinds, span.Kind()) {
+		return false
+	}
+	for key, re := range m.attributes {
+		v, ok := span.Attributes().Get(key)
+		if !ok || !re.MatchString(v.AsString()) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesAny(res []*regexp.Regexp, s string) bool {
+	return slices.ContainsFunc(res, func(re *regexp.Regexp) bool { return re.MatchString(s) })
+}
+
+func (a *AttributeAction) init() error {
+	switch a.Action {
+	case attributeActionInsert, attributeActionUpdate, attributeActionUpsert:
+		if a.Key == "" {
+			return fmt.Errorf("attribute action %s: key is required", a.Action)
+		}
+		if (a.Value == nil) == (a.FromAttribute == "") {
+			return fmt.Errorf("attribute action %s %s: exactly one of value and from_attribute is required", a.Action, a.Key)
+		}
+		if a.Value != nil {
+			if err := pcommon.NewValueEmpty().FromRaw(a.Value); err != nil {
+				return fmt.Errorf("attribute action %s %s: %w", a.Action, a.Key, err)
+			}
+		}
+	case attributeActionDelete, attributeActionHash:
+		if (a.Key == "") == (a.Pattern == "") {
+			return fmt.Errorf("attribute action %s: exactly one of key and pattern is required", a.Action)
+		}
+	case attributeActionExtract:
+		if a.Key == "" || a.Pattern == "" {
+			return fmt.Errorf("attribute action %s: key and pattern are required", a.Action)
+		}
+	default:
+		return fmt.Errorf("invalid attribute action %q", a.Action)
+	}
+	if a.Pattern != "" {
+		re, err := regexp.Compile(a.Pattern)
+		if err != nil {
+			return fmt.Errorf("attribute action %s: invalid pattern %q: %w", a.Action, a.Pattern, err)
+		}
+		if a.Action == attributeActionExtract && !slices.ContainsFunc(re.SubexpNames(), func(name string) bool { return name != "" }) {
+			return fmt.Errorf("attribute action %s: pattern %q has no named groups", a.Action, a.Pattern)
+		}
+		a.pattern = re
+	}
+	return nil
+}
+
+func (a *AttributeAction) apply(attributes pcommon.Map) {
+	switch a.Action {
+	case attributeActionInsert, attributeActionUpdate, attributeActionUpsert:
+		_, exists := attributes.Get(a.Key)
+		if (a.Action == attributeActionInsert && exists) || (a.Action == attributeActionUpdate && !exists) {
+			return
+		}
+		if a.FromAttribute != "" {
+			if v, ok := attributes.Get(a.FromAttribute); ok {
+				v.CopyTo(attributes.PutEmpty(a.Key))
+			}
+			return
+		}
+		// Validated in init
+		_ = attributes.PutEmpty(a.Key).FromRaw(a.Value)
+	case attributeActionDelete:
+		attributes.RemoveIf(func(k string, _ pcommon.Value) bool { return a.matchesKey(k) })
+	case attributeActionHash:
+		attributes.Range(func(k string, v pcommon.Value) bool {
+			if a.matchesKey(k) {
+				sum := sha256.Sum256([]byte(v.AsString()))
+				v.SetStr(hex.EncodeToString(sum[:]))
+			}
+			return true
+		})
+	case attributeActionExtract:
+		v, ok := attributes.Get(a.Key)
+		if !ok {
+			return
+		}
+		matches := a.pattern.FindStringSubmatch(v.AsString())
+		if matches == nil {
+			return
+		}
+		for i, name := range a.pattern.SubexpNames() {
+			if name != "" {
+				attributes.PutStr(name, matches[i])
+			}
+		}
+	}
+}
+
+func (a *AttributeAction) matchesKey(k string) bool {
+	if a.pattern != nil {
+		return a.pattern.MatchString(k)
+	}
+	return k == a.Key
+}
+
+func (p *AttributesProcessor) processTraces(td ptrace.Traces) {
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		resourceMatched := false
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			spans := rs.ScopeSpans().At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				span := spans.At(k)
+				if !p.matches(rs.Resource(), span) {
+					continue
+				}
+				resourceMatched = true
+				p.processSpan(span)
+			}
+		}
+		// The resource is shared by its spans, so it's changed when any of
+		// them matches
+		if resourceMatched && slices.Contains(p.Contexts, attributeContextResource) {
+			p.applyActions(rs.Resource().Attributes())
+		}
+	}
+}
+
+func (p *AttributesProcessor) matches(resource pcommon.Resource, span ptrace.Span) bool {
+	if p.Include != nil && !p.Include.matches(resource, span) {
+		return false
+	}
+	return p.Exclude == nil || !p.Exclude.matches(resource, span)
+}
+
+func (p *AttributesProcessor) processSpan(span ptrace.Span) {
+	if slices.Contains(p.Contexts, attributeContextSpan) {
+		p.applyActions(span.Attributes())
+	}
+	if slices.Contains(p.Contexts, attributeContextEvent) {
+		for i := 0; i < span.Events().Len(); i++ {
+			p.applyActions(span.Events().At(i).Attributes())
+		}
+	}
+	if slices.Contains(p.Contexts, attributeContextLink) {
+		for i := 0; i < span.Links().Len(); i++ {
+			p.applyActions(span.Links().At(i).Attributes())
+		}
+	}
+}
+
+func (p *AttributesProcessor) applyActions(attributes pcommon.Map) {
+	for _, action := range p.Actions {
+		action.apply(attributes)
+	}
+}
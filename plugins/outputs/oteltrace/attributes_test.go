@@ -0,0 +1,137 @@
+package oteltrace_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/catherinetcai/telegraf-execd-otel/plugins/outputs/oteltrace"
+	influxcommon "github.com/influxdata/influxdb-observability/common"
+	"github.com/influxdata/telegraf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func attributesSpan(name, attributes string) telegraf.Metric {
+	m := generateTraceAsMetric()
+	m.AddField(influxcommon.AttributeSpanName, name)
+	m.AddField(influxcommon.AttributeAttributes, attributes)
+	return m
+}
+
+func TestOtelTraceAttributes(t *testing.T) {
+	ot := &oteltrace.OtelTrace{
+		Attributes: []*oteltrace.AttributesProcessor{{
+			Contexts: []string{"resource", "span"},
+			Include:  &oteltrace.SpanMatch{Services: []string{"checkout"}},
+			Exclude:  &oteltrace.SpanMatch{MatchType: "regexp", SpanNames: []string{"^health"}},
+			Actions: []*oteltrace.AttributeAction{
+				{Key: "env", Action: "insert", Value: "prod"},
+				{Key: "http.method", Action: "update", Value: "GET"},
+				{Key: "team", Action: "update", Value: "payments"},
+				{Key: "peer", Action: "upsert", FromAttribute: "net.peer.ip"},
+				{Key: "user.email", Action: "hash"},
+				{Pattern: "^secret\\.", Action: "delete"},
+				{Key: "http.url", Action: "extract", Pattern: `^https?://(?P<http_host>[^/]+)(?P<http_path>/[^?]*)`},
+			},
+		}},
+	}
+	td := writeTraces(t, ot,
+		attributesSpan("pay", `{"service.name":"checkout","env":"dev","http.method":"post","net.peer.ip":"1.2.3.4","user.email":"jo@example.com","secret.token":"abc","http.url":"https://shop.example.com/pay?id=1"}`),
+	)
+	require.Equal(t, 1, td.SpanCount())
+	sum := sha256.Sum256([]byte("jo@example.com"))
+	span := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0)
+	assert.Equal(t, map[string]any{
+		"service.name": "checkout",
+		"env":          "dev",
+		"http.method":  "GET",
+		"net.peer.ip":  "1.2.3.4",
+		"peer":         "1.2.3.4",
+		"user.email":   hex.EncodeToString(sum[:]),
+		"http.url":     "https://shop.example.com/pay?id=1",
+		"http_host":    "shop.example.com",
+		"http_path":    "/pay",
+	}, span.Attributes().AsRaw())
+
+	// The resource was built from the same attributes and gets the same actions
+	resource := td.ResourceSpans().At(0).Resource().Attributes().AsRaw()
+	assert.Equal(t, "1.2.3.4", resource["peer"])
+	assert.NotContains(t, resource, "secret.token")
+}
+
+func TestOtelTraceAttributesMatch(t *testing.T) {
+	ot := &oteltrace.OtelTrace{
+		Attributes: []*oteltrace.AttributesProcessor{{
+			Include: &oteltrace.SpanMatch{Services: []string{"checkout"}},
+			Exclude: &oteltrace.SpanMatch{MatchType: "regexp", SpanNames: []string{"^health"}},
+			Actions: []*oteltrace.AttributeAction{{Key: "processed", Action: "insert", Value: true}},
+		}},
+	}
+	td := writeTraces(t, ot,
+		attributesSpan("healthz", `{"service.name":"checkout"}`),
+		attributesSpan("pay", `{"service.name":"cart"}`),
+	)
+	require.Equal(t, 2, td.SpanCount())
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		span := td.ResourceSpans().At(i).ScopeSpans().At(0).Spans().At(0)
+		assert.NotContains(t, span.Attributes().AsRaw(), "processed", span.Name())
+	}
+}
+
+func TestOtelTraceAttributesInit(t *testing.T) {
+	tests := []struct {
+		name      string
+		processor *oteltrace.AttributesProcessor
+		err       string
+	}{
+		{
+			name:      "no actions",
+			processor: &oteltrace.AttributesProcessor{},
+			err:       "attributes processor needs at least one action",
+		},
+		{
+			name: "unknown action",
+			processor: &oteltrace.AttributesProcessor{
+				Actions: []*oteltrace.AttributeAction{{Key: "a", Action: "rename"}},
+			},
+			err: `invalid attribute action "rename"`,
+		},
+		{
+			name: "insert without value",
+			processor: &oteltrace.AttributesProcessor{
+				Actions: []*oteltrace.AttributeAction{{Key: "a", Action: "insert"}},
+			},
+			err: "exactly one of value and from_attribute is required",
+		},
+		{
+			name: "extract without groups",
+			processor: &oteltrace.AttributesProcessor{
+				Actions: []*oteltrace.AttributeAction{{Key: "a", Action: "extract", Pattern: "(.*)"}},
+			},
+			err: "has no named groups",
+		},
+		{
+			name: "unknown context",
+			processor: &oteltrace.AttributesProcessor{
+				Contexts: []string{"scope"},
+				Actions:  []*oteltrace.AttributeAction{{Key: "a", Action: "delete"}},
+			},
+			err: `invalid attributes context "scope"`,
+		},
+		{
+			name: "unknown span kind",
+			processor: &oteltrace.AttributesProcessor{
+				Include: &oteltrace.SpanMatch{SpanKinds: []string{"SPAN_KIND_CLIENT", "sever"}},
+				Actions: []*oteltrace.AttributeAction{{Key: "a", Action: "delete"}},
+			},
+			err: `invalid span kind "sever"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ot := &oteltrace.OtelTrace{Attributes: []*oteltrace.AttributesProcessor{tt.processor}}
+			assert.ErrorContains(t, ot.Init(), tt.err)
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package oteltrace
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	verbosityBasic    = "basic"
+	verbosityNormal   = "normal"
+	verbosityDetailed = "detailed"
+)
+
+// DebugExporterConfig holds the settings of exporters with type "debug".
+type DebugExporterConfig struct {
+	Verbosity string `toml:"verbosity"`
+}
+
+func (c *DebugExporterConfig) init() error {
+	switch c.Verbosity {
+	case "":
+		c.Verbosity = verbosityNormal
+	case verbosityBasic, verbosityNormal, verbosityDetailed:
+	default:
+		return fmt.Errorf("unknown verbosity %q", c.Verbosity)
+	}
+	return nil
+}
+
+// debugExporter prints traces in a human readable form. It writes to stderr
+// as stdout carries the execd protocol.
+type debugExporter struct {
+	verbosity string
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func newDebugExporter(w io.Writer, c DebugExporterConfig) *debugExporter {
+	return &debugExporter{verbosity: c.Verbosity, w: w}
+}
+
+func (e *debugExporter) Export(_ context.Context, td ptrace.Traces) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Traces: %d resource spans, %d spans\n", td.ResourceSpans().Len(), td.SpanCount())
+	if e.verbosity != verbosityBasic {
+		e.writeSpanTrees(&b, td)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err := io.WriteString(e.w, b.String())
+	return err
+}
+
+// debugSpan is a span along with the index of the resource it belongs to.
+type debugSpan struct {
+	ptrace.Span
+	resource int
+}
+
+// writeSpanTrees prints each trace as a tree, following parent span IDs
+// across resources. Spans whose parent isn't in the batch are printed as
+// roots.
+func (e *debugExporter) writeSpanTrees(b *strings.Builder, td ptrace.Traces) {
+	type spanKey struct {
+		traceID pcommon.TraceID
+		spanID  pcommon.SpanID
+	}
+	spans := map[spanKey]debugSpan{}
+	children := map[spanKey][]debugSpan{}
+	var roots []debugSpan
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		if e.verbosity == verbosityDetailed {
+			writeAttributes(b, "", fmt.Sprintf("Resource #%d attributes", i), rs.Resource().Attributes())
+		}
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			ss := rs.ScopeSpans().At(j)
+			for k := 0; k < ss.Spans().Len(); k++ {
+				span := ss.Spans().At(k)
+				spans[spanKey{span.TraceID(), span.SpanID()}] = debugSpan{Span: span, resource: i}
+			}
+		}
+	}
+	for _, span := range spans {
+		parent := spanKey{span.TraceID(), span.ParentSpanID()}
+		if _, ok := spans[parent]; ok && parent.spanID != span.SpanID() {
+			children[parent] = append(children[parent], span)
+		} else {
+			roots = append(roots, span)
+		}
+	}
+
+	sortSpans(roots)
+	var traceID pcommon.TraceID
+	for i, root := range roots {
+		if i == 0 || root.TraceID() != traceID {
+			traceID = root.TraceID()
+			fmt.Fprintf(b, "Trace %s\n", traceID)
+		}
+		e.writeSpanTree(b, "  ", root, func(span debugSpan) []debugSpan {
+			return children[spanKey{span.TraceID(), span.SpanID()}]
+		})
+	}
+}
+
+func (e *debugExporter) writeSpanTree(b *strings.Builder, indent string, span debugSpan, children func(debugSpan) []debugSpan) {
+	duration := span.EndTimestamp().AsTime().Sub(span.StartTimestamp().AsTime())
+	if span.EndTimestamp() < span.StartTimestamp() {
+		duration = 0
+	}
+	fmt.Fprintf(b, "%s- %s [%s] %s status=%s span_id=%s",
+		indent, span.Name(), trace.SpanKind(span.Kind()), duration, statusString(span.Status()), span.SpanID())
+	if e.verbosity == verbosityDetailed {
+		fmt.Fprintf(b, " resource=#%d", span.resource)
+	}
+	b.WriteString("\n")
+
+	if e.verbosity == verbosityDetailed {
+		details := indent + "    "
+		writeAttributes(b, details, "Attributes", span.Attributes())
+		for i := 0; i < span.Events().Len(); i++ {
+			event := span.Events().At(i)
+			fmt.Fprintf(b, "%sEvent %s at %s\n", details, event.Name(), event.Timestamp())
+			writeAttributes(b, details+"  ", "Attributes", event.Attributes())
+		}
+		for i := 0; i < span.Links().Len(); i++ {
+			link := span.Links().At(i)
+			fmt.Fprintf(b, "%sLink trace_id=%s span_id=%s\n", details, link.TraceID(), link.SpanID())
+			writeAttributes(b, details+"  ", "Attributes", link.Attributes())
+		}
+	}
+
+	kids := children(span)
+	sortSpans(kids)
+	for _, child := range kids {
+		e.writeSpanTree(b, indent+"  ", child, children)
+	}
+}
+
+func statusString(status ptrace.Status) string {
+	if status.Message() == "" {
+		return status.Code().String()
+	}
+	return fmt.Sprintf("%s (%s)", status.Code(), status.Message())
+}
+
+func writeAttributes(b *strings.Builder, indent, title string, attributes pcommon.Map) {
+	if attributes.Len() == 0 {
+		return
+	}
+	fmt.Fprintf(b, "%s%s:\n", indent, title)
+	keys := make([]string, 0, attributes.Len())
+	attributes.Range(func(k string, _ pcommon.Value) bool {
+		keys = append(keys, k)
+		return true
+	})
+	sort.Strings(keys)
+	for _, k := range keys {
+		v, _ := attributes.Get(k)
+		fmt.Fprintf(b, "%s  %s: %s(%s)\n", indent, k, v.Type(), v.AsString())
+	}
+}
+
+// sortSpans orders spans by trace, then start time.
+func sortSpans(spans []debugSpan) {
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i].TraceID() != spans[j].TraceID() {
+			return spans[i].TraceID().String() < spans[j].TraceID().String()
+		}
+		if spans[i].StartTimestamp() != spans[j].StartTimestamp() {
+			return spans[i].StartTimestamp() < spans[j].StartTimestamp()
+		}
+		return spans[i].Name() < spans[j].Name()
+	})
+}
+
+func (e *debugExporter) Close() error {
+	return nil
+}
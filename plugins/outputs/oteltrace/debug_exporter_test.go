@@ -0,0 +1,94 @@
+package oteltrace_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/catherinetcai/telegraf-execd-otel/plugins/outputs/oteltrace"
+	influxcommon "github.com/influxdata/influxdb-observability/common"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureStderr points os.Stderr at a file for the rest of the test and
+// returns a function reading back what was written.
+func captureStderr(t *testing.T) func() string {
+	path := filepath.Join(t.TempDir(), "stderr")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	stderr := os.Stderr
+	os.Stderr = f
+	t.Cleanup(func() {
+		os.Stderr = stderr
+		f.Close()
+	})
+	return func() string {
+		out, err := os.ReadFile(path)
+		require.NoError(t, err)
+		return string(out)
+	}
+}
+
+func parentChildSpanMetrics() []telegraf.Metric {
+	parent := generateTraceAsMetric()
+	child := generateTraceAsMetric()
+	child.AddTag(influxcommon.AttributeSpanID, "000000000000000b")
+	child.AddField(influxcommon.AttributeSpanName, "child")
+	child.AddField(influxcommon.AttributeParentSpanID, "000000000000000a")
+	child.AddField(influxcommon.AttributeEndTimeUnixNano, child.Time().UnixNano()+5e6)
+	child.AddField(influxcommon.AttributeAttributes, `{"http.method":"GET"}`)
+	return []telegraf.Metric{child, parent}
+}
+
+func TestOtelTraceDebug(t *testing.T) {
+	output := captureStderr(t)
+	traces := &recordingTracesServer{}
+	ot := &oteltrace.OtelTrace{
+		Debug:          true,
+		ServiceAddress: startTracesServer(t, traces),
+		Log:            &testutil.Logger{},
+	}
+	require.NoError(t, ot.Init())
+	require.NoError(t, ot.Connect())
+	defer ot.Close()
+
+	require.NoError(t, ot.Write(parentChildSpanMetrics()))
+	// Debug output doesn't replace the real exporter
+	assert.Len(t, traces.requests, 1)
+	assert.Equal(t, `Traces: 2 resource spans, 2 spans
+Trace 00000000000000000000000000000001
+  - fakespan [server] 0s status=Unset span_id=000000000000000a
+    - child [server] 5ms status=Unset span_id=000000000000000b
+`, output())
+}
+
+func TestOtelTraceDebugExporter(t *testing.T) {
+	output := captureStderr(t)
+	ot := &oteltrace.OtelTrace{
+		Exporters: []*oteltrace.ExporterConfig{
+			{
+				Name:                "debug",
+				Type:                "debug",
+				DebugExporterConfig: oteltrace.DebugExporterConfig{Verbosity: "detailed"},
+			},
+		},
+		Log: &testutil.Logger{},
+	}
+	require.NoError(t, ot.Init())
+	require.NoError(t, ot.Connect())
+	defer ot.Close()
+
+	require.NoError(t, ot.Write(parentChildSpanMetrics()))
+	out := output()
+	// Named exporters get the whole batch too, so the child is printed under
+	// its parent. The order of the resources follows the batch's.
+	assert.Contains(t, out, "Traces: 2 resource spans, 2 spans\n")
+	assert.Contains(t, out, "attributes:\n  http.method: Str(GET)\n")
+	assert.Regexp(t, `(?m)^  - fakespan \[server\] 0s status=Unset span_id=000000000000000a resource=#\d\n`+
+		`    - child \[server\] 5ms status=Unset span_id=000000000000000b resource=#\d\n`+
+		`        Attributes:\n`+
+		`          http.method: Str\(GET\)\n`, out)
+}
@@ -0,0 +1,442 @@
+package oteltrace
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf/config"
+	commontls "github.com/influxdata/telegraf/plugins/common/tls"
+	"github.com/influxdata/telegraf/selfstat"
+	"github.com/jaegertracing/jaeger/proto-gen/api_v2"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	exporterOTLP   = "otlp"
+	exporterZipkin = "zipkin"
+	exporterJaeger = "jaeger"
+	exporterFile   = "file"
+	exporterDebug  = "debug"
+	exporterKafka  = "kafka"
+
+	protocolGRPC = "grpc"
+	protocolHTTP = "http"
+
+	defaultExporterTimeout       = config.Duration(10 * time.Second)
+	defaultExporterRetryInterval = config.Duration(time.Second)
+)
+
+// traceExporter sends converted traces to a single destination.
+type traceExporter interface {
+	Export(ctx context.Context, td ptrace.Traces) error
+	Close() error
+}
+
+// ExporterConfig is a named destination that every converted trace is
+// replicated to. Each exporter has its own auth, timeout and retries so that
+// a failing or slow backend doesn't hold up the others.
+type ExporterConfig struct {
+	Name           string            `toml:"name"`
+	Type           string            `toml:"type"`
+	Protocol       string            `toml:"protocol"`
+	ServiceAddress string            `toml:"service_address"`
+	Headers        map[string]string `toml:"headers"`
+	OAuth2         *OAuth2Config     `toml:"oauth2"`
+	Timeout        config.Duration   `toml:"timeout"`
+	MaxRetries     int               `toml:"max_retries"`
+	RetryInterval  config.Duration   `toml:"retry_interval"`
+	commontls.ClientConfig
+	FileExporterConfig
+	DebugExporterConfig
+	KafkaExporterConfig
+
+	tlsConfig   *tls.Config
+	tokenSource *oauth2TokenSource
+}
+
+func (c *ExporterConfig) init() error {
+	if c.Name == "" {
+		return fmt.Errorf("exporter name is required")
+	}
+	switch c.Type {
+	case "":
+		c.Type = exporterOTLP
+	case exporterOTLP, exporterZipkin, exporterJaeger:
+	case exporterFile:
+		if err := c.FileExporterConfig.init(); err != nil {
+			return fmt.Errorf("exporter %s: %w", c.Name, err)
+		}
+	case exporterDebug:
+		if err := c.DebugExporterConfig.init(); err != nil {
+			return fmt.Errorf("exporter %s: %w", c.Name, err)
+		}
+	case exporterKafka:
+		if err := c.KafkaExporterConfig.init(); err != nil {
+			return fmt.Errorf("exporter %s: %w", c.Name, err)
+		}
+	default:
+		return fmt.Errorf("exporter %s: unknown type %q", c.Name, c.Type)
+	}
+	// Only the exporters talking to a collector have an address
+	needsAddress := c.Type == exporterOTLP || c.Type == exporterZipkin || c.Type == exporterJaeger
+	if c.ServiceAddress == "" && needsAddress {
+		return fmt.Errorf("exporter %s: service_address is required", c.Name)
+	}
+	switch c.Protocol {
+	case "":
+		c.Protocol = protocolGRPC
+	case protocolGRPC, protocolHTTP:
+	default:
+		return fmt.Errorf("exporter %s: unknown protocol %q", c.Name, c.Protocol)
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaultExporterTimeout
+	}
+	if c.MaxRetries < 0 {
+		return fmt.Errorf("exporter %s: max_retries can't be negative", c.Name)
+	}
+	if c.RetryInterval <= 0 {
+		c.RetryInterval = defaultExporterRetryInterval
+	}
+	tlsConfig, err := c.ClientConfig.TLSConfig()
+	if err != nil {
+		return fmt.Errorf("exporter %s: %w", c.Name, err)
+	}
+	c.tlsConfig = tlsConfig
+	if c.OAuth2 != nil {
+		tokenSource, err := c.OAuth2.tokenSource(tlsDisabled(c.ClientConfig))
+		if err != nil {
+			return fmt.Errorf("exporter %s: %w", c.Name, err)
+		}
+		c.tokenSource = tokenSource
+	}
+	return nil
+}
+
+// namedExporter pairs a connected exporter with its retry settings.
+type namedExporter struct {
+	name          string
+	timeout       time.Duration
+	maxRetries    int
+	retryInterval time.Duration
+	exporter      traceExporter
+	// dropped counts the batches this exporter failed while another one
+	// succeeded, which Telegraf doesn't retry
+	dropped selfstat.Stat
+}
+
+func (o *OtelTrace) newExporter(c *ExporterConfig) (*namedExporter, error) {
+	var exporter traceExporter
+	switch {
+	case c.Type == exporterDebug:
+		exporter = newDebugExporter(os.Stderr, c.DebugExporterConfig)
+	case c.Type == exporterKafka:
+		kafkaExporter, err := newKafkaExporter(c)
+		if err != nil {
+			return nil, err
+		}
+		exporter = kafkaExporter
+	case c.Type == exporterFile:
+		fileExporter, err := newFileExporter(c.FileExporterConfig, o.Log)
+		if err != nil {
+			return nil, err
+		}
+		exporter = fileExporter
+	case c.Type == exporterZipkin:
+		exporter = newZipkinExporter(c)
+	case c.Type == exporterJaeger:
+		conn, err := o.dial(c.ServiceAddress, c.tlsConfig, c.tokenSource, grpc.WithDefaultCallOptions(grpc.ForceCodec(jaegerCodec{})))
+		if err != nil {
+			return nil, err
+		}
+		exporter = &jaegerExporter{
+			client:  api_v2.NewCollectorServiceClient(conn),
+			conn:    conn,
+			headers: c.Headers,
+		}
+	case c.Protocol == protocolGRPC:
+		conn, err := o.dial(c.ServiceAddress, c.tlsConfig, c.tokenSource)
+		if err != nil {
+			return nil, err
+		}
+		exporter = &otlpGRPCExporter{
+			client:        ptraceotlp.NewGRPCClient(conn),
+			metricsClient: pmetricotlp.NewGRPCClient(conn),
+			logsClient:    plogotlp.NewGRPCClient(conn),
+			conn:          conn,
+			headers:       c.Headers,
+		}
+	case c.Protocol == protocolHTTP:
+		exporter = newOTLPHTTPExporter(c)
+	}
+	return &namedExporter{
+		name:          c.Name,
+		timeout:       time.Duration(c.Timeout),
+		maxRetries:    c.MaxRetries,
+		retryInterval: time.Duration(c.RetryInterval),
+		exporter:      exporter,
+		dropped:       selfstat.Register("oteltrace", "exporter_dropped_batches", map[string]string{"exporter": c.Name}),
+	}, nil
+}
+
+func (e *namedExporter) send(ctx context.Context, td ptrace.Traces) error {
+	return e.retry(ctx, func(ctx context.Context) error {
+		return e.exporter.Export(ctx, td)
+	})
+}
+
+// retry calls export until it succeeds or the exporter's retries run out,
+// giving each attempt the exporter's timeout.
+func (e *namedExporter) retry(ctx context.Context, export func(context.Context) error) error {
+	var err error
+	for attempt := 0; attempt <= e.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(e.retryInterval):
+			}
+		}
+		attemptCtx, cancel := context.WithTimeout(ctx, e.timeout)
+		err = export(attemptCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// fanOut replicates td to every exporter concurrently.
+func (o *OtelTrace) fanOut(ctx context.Context, exporters []*namedExporter, td ptrace.Traces) error {
+	return o.replicate(ctx, exporters, "traces", func(ctx context.Context, e *namedExporter) error {
+		traces := td
+		if len(exporters) > 1 {
+			traces = ptrace.NewTraces()
+			td.CopyTo(traces)
+		}
+		return e.send(ctx, traces)
+	})
+}
+
+// replicate calls send for every exporter concurrently. Failures are logged
+// per exporter and only reported back to Telegraf when no exporter succeeded,
+// so the healthy backends don't receive the batch twice on retry. The failed
+// exporters lose the batch then, which is counted in their dropped stat.
+// The write waits for every exporter, so FanOutTimeout cancels the ones still
+// exporting or retrying once it's up.
+func (o *OtelTrace) replicate(ctx context.Context, exporters []*namedExporter, signal string, send func(context.Context, *namedExporter) error) error {
+	if o.FanOutTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(o.FanOutTimeout))
+		defer cancel()
+	}
+	errs := make([]error, len(exporters))
+	wg := sync.WaitGroup{}
+	for i, e := range exporters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := send(ctx, e); err != nil {
+				errs[i] = fmt.Errorf("failed to export %s to %s: %w", signal, e.name, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			o.Log.Error(err)
+			failed++
+		}
+	}
+	if failed == len(exporters) {
+		return errors.Join(errs...)
+	}
+	for i, err := range errs {
+		if err != nil {
+			exporters[i].dropped.Incr(1)
+		}
+	}
+	return nil
+}
+
+type otlpGRPCExporter struct {
+	client        ptraceotlp.GRPCClient
+	metricsClient pmetricotlp.GRPCClient
+	logsClient    plogotlp.GRPCClient
+	conn          *grpc.ClientConn
+	headers       map[string]string
+}
+
+func (e *otlpGRPCExporter) Export(ctx context.Context, td ptrace.Traces) error {
+	_, err := e.client.Export(withHeaders(ctx, e.headers), ptraceotlp.NewExportRequestFromTraces(td))
+	return err
+}
+
+func (e *otlpGRPCExporter) Close() error {
+	if e.conn != nil {
+		return e.conn.Close()
+	}
+	return nil
+}
+
+// withHeaders layers an exporter's own headers over the route and top level
+// ones already in the outgoing metadata.
+func withHeaders(ctx context.Context, headers map[string]string) context.Context {
+	if len(headers) == 0 {
+		return ctx
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	for k, v := range headers {
+		md.Set(k, v)
+	}
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// otlpHTTPExporter posts protobuf encoded export requests to an OTLP/HTTP
+// endpoint.
+type otlpHTTPExporter struct {
+	*httpSender
+	metricsSender *httpSender
+	logsSender    *httpSender
+}
+
+func newOTLPHTTPExporter(c *ExporterConfig) *otlpHTTPExporter {
+	// Like the collector's otlphttp exporter, a bare endpoint gets the
+	// signal path appended.
+	e := &otlpHTTPExporter{
+		httpSender: newHTTPSender(c, "/v1/traces", "application/x-protobuf"),
+	}
+	e.metricsSender = e.signalSender(c, "/v1/metrics")
+	e.logsSender = e.signalSender(c, "/v1/logs")
+	return e
+}
+
+// signalSender sends another signal to the same collector. An explicit traces
+// URL has its signal path swapped.
+func (e *otlpHTTPExporter) signalSender(c *ExporterConfig, path string) *httpSender {
+	s := newHTTPSender(c, path, "application/x-protobuf")
+	if url, ok := strings.CutSuffix(e.url, "/v1/traces"); ok {
+		s.url = url + path
+	}
+	return s
+}
+
+func (e *otlpHTTPExporter) Export(ctx context.Context, td ptrace.Traces) error {
+	body, err := ptraceotlp.NewExportRequestFromTraces(td).MarshalProto()
+	if err != nil {
+		return fmt.Errorf("failed to marshal export request: %w", err)
+	}
+	return e.send(ctx, body)
+}
+
+func (e *otlpHTTPExporter) Close() error {
+	e.metricsSender.Close()
+	e.logsSender.Close()
+	return e.httpSender.Close()
+}
+
+// httpSender posts already encoded payloads for the HTTP based exporters.
+type httpSender struct {
+	url         string
+	contentType string
+	headers     map[string]string
+	tokenSource *oauth2TokenSource
+	client      *http.Client
+}
+
+// newHTTPSender appends defaultPath to addresses that don't have a path of
+// their own.
+func newHTTPSender(c *ExporterConfig, defaultPath, contentType string) *httpSender {
+	url := c.ServiceAddress
+	if !strings.Contains(url, "://") {
+		url = "http://" + url
+		if c.tlsConfig != nil {
+			url = "https://" + c.ServiceAddress
+		}
+	}
+	if strings.Count(url, "/") == 2 {
+		url += defaultPath
+	}
+	return &httpSender{
+		url:         url,
+		contentType: contentType,
+		headers:     c.Headers,
+		tokenSource: c.tokenSource,
+		client: &http.Client{
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: c.tlsConfig,
+			},
+		},
+	}
+}
+
+func (s *httpSender) send(ctx context.Context, body []byte) error {
+	statusCode, err := s.post(ctx, body)
+	if statusCode == http.StatusUnauthorized && s.tokenSource != nil {
+		s.tokenSource.invalidate()
+		_, err = s.post(ctx, body)
+	}
+	return err
+}
+
+func (s *httpSender) post(ctx context.Context, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", s.contentType)
+	// Route and top level headers travel as gRPC metadata, the exporter's own
+	// headers take precedence over them.
+	md, _ := metadata.FromOutgoingContext(ctx)
+	for k, v := range md {
+		req.Header[http.CanonicalHeaderKey(k)] = v
+	}
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	if s.tokenSource != nil {
+		if req.URL.Scheme != "https" && s.tokenSource.RequireTransportSecurity() {
+			return 0, fmt.Errorf("oauth2 tokens are only sent over TLS, set tls_enable = false to send them to %s", s.url)
+		}
+		token, err := s.tokenSource.Token(ctx)
+		if err != nil {
+			return 0, err
+		}
+		token.SetAuthHeader(req)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return resp.StatusCode, fmt.Errorf("%s returned %s: %s", s.url, resp.Status, msg)
+	}
+	return resp.StatusCode, nil
+}
+
+func (s *httpSender) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,150 @@
+package oteltrace_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/catherinetcai/telegraf-execd-otel/plugins/outputs/oteltrace"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/selfstat"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+)
+
+func TestOtelTraceFanOut(t *testing.T) {
+	grpcServer := &recordingTracesServer{rejectFirst: 1}
+	var httpRequests atomic.Int32
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "new-backend", r.Header.Get("X-Api-Key"))
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		request := ptraceotlp.NewExportRequest()
+		assert.NoError(t, request.UnmarshalProto(body))
+		assert.Equal(t, 1, request.Traces().SpanCount())
+		httpRequests.Add(1)
+	}))
+	t.Cleanup(httpServer.Close)
+
+	ot := &oteltrace.OtelTrace{
+		Exporters: []*oteltrace.ExporterConfig{
+			{
+				Name:           "old",
+				ServiceAddress: startTracesServer(t, grpcServer),
+				MaxRetries:     1,
+				RetryInterval:  config.Duration(time.Millisecond),
+			},
+			{
+				Name:           "new",
+				Protocol:       "http",
+				ServiceAddress: httpServer.URL,
+				Headers:        map[string]string{"X-Api-Key": "new-backend"},
+			},
+			{
+				Name:           "unreachable",
+				Protocol:       "http",
+				ServiceAddress: "127.0.0.1:1",
+				Timeout:        config.Duration(100 * time.Millisecond),
+			},
+		},
+		Log: &testutil.Logger{},
+	}
+	require.NoError(t, ot.Init())
+	require.NoError(t, ot.Connect())
+	defer ot.Close()
+	dropped := func(name string) int64 {
+		return selfstat.Register("oteltrace", "exporter_dropped_batches", map[string]string{"exporter": name}).Get()
+	}
+	before := map[string]int64{}
+	for _, e := range ot.Exporters {
+		before[e.Name] = dropped(e.Name)
+	}
+
+	// One broken exporter doesn't fail the write for the others, it loses
+	// the batch instead
+	require.NoError(t, ot.Write([]telegraf.Metric{generateTraceAsMetric()}))
+	assert.Len(t, grpcServer.requests, 1)
+	assert.Equal(t, int32(1), httpRequests.Load())
+	assert.Equal(t, before["old"], dropped("old"), "retried exports aren't dropped")
+	assert.Equal(t, before["new"], dropped("new"))
+	assert.Equal(t, before["unreachable"]+1, dropped("unreachable"))
+}
+
+func TestOtelTraceFanOutAllFailed(t *testing.T) {
+	ot := &oteltrace.OtelTrace{
+		Exporters: []*oteltrace.ExporterConfig{
+			{
+				Name:           "unreachable",
+				Protocol:       "http",
+				ServiceAddress: "127.0.0.1:1",
+				Timeout:        config.Duration(100 * time.Millisecond),
+			},
+		},
+		Log: &testutil.Logger{Quiet: true},
+	}
+	require.NoError(t, ot.Init())
+	require.NoError(t, ot.Connect())
+	defer ot.Close()
+
+	assert.ErrorContains(t, ot.Write([]telegraf.Metric{generateTraceAsMetric()}), "unreachable")
+}
+
+func TestOtelTraceFanOutTimeout(t *testing.T) {
+	grpcServer := &recordingTracesServer{}
+	release := make(chan struct{})
+	hanging := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	t.Cleanup(hanging.Close)
+	t.Cleanup(func() { close(release) })
+
+	ot := &oteltrace.OtelTrace{
+		FanOutTimeout: config.Duration(200 * time.Millisecond),
+		Exporters: []*oteltrace.ExporterConfig{
+			{
+				Name:           "healthy",
+				ServiceAddress: startTracesServer(t, grpcServer),
+			},
+			{
+				Name:           "hanging",
+				Protocol:       "http",
+				ServiceAddress: hanging.URL,
+				Timeout:        config.Duration(5 * time.Second),
+				MaxRetries:     2,
+			},
+		},
+		Log: &testutil.Logger{Quiet: true},
+	}
+	require.NoError(t, ot.Init())
+	require.NoError(t, ot.Connect())
+	defer ot.Close()
+	dropped := selfstat.Register("oteltrace", "exporter_dropped_batches", map[string]string{"exporter": "hanging"})
+	before := dropped.Get()
+
+	// The hanging exporter would hold the write for 15s without the deadline
+	start := time.Now()
+	require.NoError(t, ot.Write([]telegraf.Metric{generateTraceAsMetric()}))
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.Len(t, grpcServer.requests, 1)
+	assert.Equal(t, before+1, dropped.Get())
+}
+
+func TestOtelTraceExportersInit(t *testing.T) {
+	ot := &oteltrace.OtelTrace{
+		Exporters: []*oteltrace.ExporterConfig{{Name: "old", ServiceAddress: "localhost:4317"}},
+		Routes: []*oteltrace.Route{
+			{Name: "team-a", Key: "service.namespace", Values: []string{"a"}, Exporters: []string{"new"}},
+		},
+	}
+	assert.ErrorContains(t, ot.Init(), "unknown exporter new")
+}
@@ -0,0 +1,237 @@
+package oteltrace
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+const (
+	fileFormatJSON  = "json"
+	fileFormatProto = "proto"
+
+	// Sorts lexically and is safe in file names on every platform
+	rotatedFileTimeFormat = "2006-01-02T15-04-05.000000000"
+)
+
+// FileExporterConfig holds the settings of exporters with type "file".
+type FileExporterConfig struct {
+	Path                string          `toml:"path"`
+	Format              string          `toml:"format"`
+	RotationInterval    config.Duration `toml:"rotation_interval"`
+	RotationMaxSize     config.Size     `toml:"rotation_max_size"`
+	RotationMaxArchives int             `toml:"rotation_max_archives"`
+	RotationCompress    bool            `toml:"rotation_compress"`
+}
+
+func (c *FileExporterConfig) init() error {
+	if c.Path == "" {
+		return fmt.Errorf("path is required")
+	}
+	switch c.Format {
+	case "":
+		c.Format = fileFormatJSON
+	case fileFormatJSON, fileFormatProto:
+	default:
+		return fmt.Errorf("unknown file format %q", c.Format)
+	}
+	return nil
+}
+
+// fileExporter appends traces to a file as OTLP JSON lines, which the
+// collector's otlpjsonfile receiver reads back, or as varint length delimited
+// protobuf export requests.
+type fileExporter struct {
+	FileExporterConfig
+	log telegraf.Logger
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+func newFileExporter(c FileExporterConfig, log telegraf.Logger) (*fileExporter, error) {
+	e := &fileExporter{FileExporterConfig: c, log: log}
+	if err := e.open(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *fileExporter) Export(_ context.Context, td ptrace.Traces) error {
+	var buf []byte
+	switch e.Format {
+	case fileFormatJSON:
+		b, err := (&ptrace.JSONMarshaler{}).MarshalTraces(td)
+		if err != nil {
+			return fmt.Errorf("failed to marshal traces: %w", err)
+		}
+		buf = append(b, '\n')
+	case fileFormatProto:
+		b, err := (&ptrace.ProtoMarshaler{}).MarshalTraces(td)
+		if err != nil {
+			return fmt.Errorf("failed to marshal traces: %w", err)
+		}
+		buf = binary.AppendUvarint(buf, uint64(len(b)))
+		buf = append(buf, b...)
+	}
+	return e.write(buf)
+}
+
+func (e *fileExporter) write(buf []byte) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.shouldRotate(int64(len(buf))) {
+		if err := e.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := e.file.Write(buf)
+	e.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write traces to %s: %w", e.Path, err)
+	}
+	return nil
+}
+
+func (e *fileExporter) shouldRotate(pending int64) bool {
+	if e.size == 0 {
+		return false
+	}
+	if e.RotationMaxSize > 0 && e.size+pending > int64(e.RotationMaxSize) {
+		return true
+	}
+	return e.RotationInterval > 0 && time.Since(e.openedAt) >= time.Duration(e.RotationInterval)
+}
+
+func (e *fileExporter) open() error {
+	if err := os.MkdirAll(filepath.Dir(e.Path), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", e.Path, err)
+	}
+	file, err := os.OpenFile(e.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", e.Path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat %s: %w", e.Path, err)
+	}
+	e.file = file
+	e.size = info.Size()
+	e.openedAt = time.Now()
+	return nil
+}
+
+// rotate moves the current file aside and starts a new one, then compresses
+// the archive if configured and drops archives beyond the retention count.
+// The exporter always ends up with an open file, so a failed rotation doesn't
+// fail every later export; once the new file is open, compression and
+// retention failures are only logged.
+func (e *fileExporter) rotate() error {
+	if err := e.file.Close(); err != nil {
+		return errors.Join(fmt.Errorf("failed to close %s: %w", e.Path, err), e.open())
+	}
+	ext := filepath.Ext(e.Path)
+	stem := strings.TrimSuffix(e.Path, ext)
+	archive := stem + "." + time.Now().UTC().Format(rotatedFileTimeFormat) + ext
+	if err := os.Rename(e.Path, archive); err != nil {
+		return errors.Join(fmt.Errorf("failed to rotate %s: %w", e.Path, err), e.open())
+	}
+	if err := e.open(); err != nil {
+		return err
+	}
+	if e.RotationCompress {
+		if err := gzipFile(archive); err != nil {
+			e.log.Errorf("file exporter: %s", err)
+		}
+	}
+	if err := e.removeOldArchives(stem, ext); err != nil {
+		e.log.Errorf("file exporter: %s", err)
+	}
+	return nil
+}
+
+func (e *fileExporter) removeOldArchives(stem, ext string) error {
+	if e.RotationMaxArchives <= 0 {
+		return nil
+	}
+	matches, err := filepath.Glob(stem + ".*" + ext + "*")
+	if err != nil {
+		return err
+	}
+	// The glob also matches files of other programs, e.g. traces.old.json,
+	// which must not be removed
+	var archives []string
+	for _, match := range matches {
+		if isArchive(match, stem, ext) {
+			archives = append(archives, match)
+		}
+	}
+	sort.Strings(archives)
+	for len(archives) > e.RotationMaxArchives {
+		if err := os.Remove(archives[0]); err != nil {
+			return fmt.Errorf("failed to remove old archive %s: %w", archives[0], err)
+		}
+		archives = archives[1:]
+	}
+	return nil
+}
+
+// isArchive reports whether path is named the way rotate names the archives
+// of stem and ext.
+func isArchive(path, stem, ext string) bool {
+	name := strings.TrimSuffix(path, ".gz")
+	if !strings.HasPrefix(name, stem+".") || !strings.HasSuffix(name, ext) {
+		return false
+	}
+	timestamp := strings.TrimSuffix(strings.TrimPrefix(name, stem+"."), ext)
+	t, err := time.Parse(rotatedFileTimeFormat, timestamp)
+	return err == nil && t.Format(rotatedFileTimeFormat) == timestamp
+}
+
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(path + ".gz")
+	if err != nil {
+		return fmt.Errorf("failed to create %s.gz: %w", path, err)
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		dst.Close()
+		return fmt.Errorf("failed to compress %s: %w", path, err)
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		return fmt.Errorf("failed to compress %s: %w", path, err)
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func (e *fileExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.file.Close()
+}
@@ -0,0 +1,120 @@
+package oteltrace_test
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/catherinetcai/telegraf-execd-otel/plugins/outputs/oteltrace"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestOtelTraceFileExporter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "traces.json")
+	ot := &oteltrace.OtelTrace{
+		Exporters: []*oteltrace.ExporterConfig{
+			{
+				Name: "file",
+				Type: "file",
+				FileExporterConfig: oteltrace.FileExporterConfig{
+					Path: path,
+					// Small enough that every export lands in its own file
+					RotationMaxSize:     100,
+					RotationMaxArchives: 2,
+					RotationCompress:    true,
+				},
+			},
+		},
+		Log: &testutil.Logger{},
+	}
+	// Files the exporter didn't rotate are never removed
+	unrelated := []string{filepath.Join(dir, "traces.0.json"), filepath.Join(dir, "traces.old.json.gz")}
+	for _, name := range unrelated {
+		require.NoError(t, os.WriteFile(name, nil, 0o600))
+	}
+	require.NoError(t, ot.Init())
+	require.NoError(t, ot.Connect())
+	for i := 0; i < 4; i++ {
+		require.NoError(t, ot.Write([]telegraf.Metric{generateTraceAsMetric()}))
+	}
+	require.NoError(t, ot.Close())
+
+	for _, name := range unrelated {
+		assert.FileExists(t, name)
+	}
+	archives, err := filepath.Glob(filepath.Join(dir, "traces.2*.json.gz"))
+	require.NoError(t, err)
+	require.Len(t, archives, 2)
+
+	// Both the active file and the archives hold OTLP JSON lines
+	f, err := os.Open(archives[0])
+	require.NoError(t, err)
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	assertOTLPJSONLines(t, zr, 1)
+
+	active, err := os.Open(path)
+	require.NoError(t, err)
+	defer active.Close()
+	assertOTLPJSONLines(t, active, 1)
+}
+
+func assertOTLPJSONLines(t *testing.T, r io.Reader, expected int) {
+	t.Helper()
+	scanner := bufio.NewScanner(r)
+	lines := 0
+	for scanner.Scan() {
+		td, err := (&ptrace.JSONUnmarshaler{}).UnmarshalTraces(scanner.Bytes())
+		require.NoError(t, err)
+		assert.Equal(t, "fakespan", td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Name())
+		lines++
+	}
+	require.NoError(t, scanner.Err())
+	assert.Equal(t, expected, lines)
+}
+
+func TestOtelTraceFileExporterRetentionFailure(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "traces.json")
+	// An archive that can't be removed, as it's a directory with a file in it
+	stuck := filepath.Join(dir, "traces.2000-01-01T00-00-00.000000000.json")
+	require.NoError(t, os.MkdirAll(filepath.Join(stuck, "keep"), 0o755))
+	log := &testutil.CaptureLogger{}
+	ot := &oteltrace.OtelTrace{
+		Exporters: []*oteltrace.ExporterConfig{
+			{
+				Name: "file",
+				Type: "file",
+				FileExporterConfig: oteltrace.FileExporterConfig{
+					Path:                path,
+					RotationMaxSize:     100,
+					RotationMaxArchives: 1,
+				},
+			},
+		},
+		Log: log,
+	}
+	require.NoError(t, ot.Init())
+	require.NoError(t, ot.Connect())
+	// Failing to drop old archives doesn't stop the exporter writing
+	for i := 0; i < 3; i++ {
+		require.NoError(t, ot.Write([]telegraf.Metric{generateTraceAsMetric()}))
+	}
+	require.NoError(t, ot.Close())
+	assert.NotEmpty(t, log.Errors())
+	assert.DirExists(t, stuck)
+
+	active, err := os.Open(path)
+	require.NoError(t, err)
+	defer active.Close()
+	assertOTLPJSONLines(t, active, 1)
+}
@@ -0,0 +1,227 @@
+package oteltrace
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/selfstat"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	semconv "go.opentelemetry.io/collector/semconv/v1.16.0"
+)
+
+const (
+	filterActionInclude = "include"
+	filterActionExclude = "exclude"
+)
+
+// SpanFilter keeps or drops the spans matching all of its criteria. When
+// include filters are configured only spans matching one of them are kept;
+// spans matching any exclude filter are dropped.
+type SpanFilter struct {
+	Name              string            `toml:"name"`
+	Action            string            `toml:"action"`
+	SpanNames         []string          `toml:"span_names"`
+	Services          []string          `toml:"services"`
+	SpanKinds         []string          `toml:"span_kinds"`
+	StatusCodes       []string          `toml:"status_codes"`
+	Attributes        map[string]string `toml:"attributes"`
+	AttributePatterns map[string]string `toml:"attribute_patterns"`
+	MinDuration       config.Duration   `toml:"min_duration"`
+	// DropTrace drops every span of a trace in the batch when the trace's
+	// root span matches an exclude filter.
+	DropTrace bool `toml:"drop_trace"`
+
+	spanNames         []*regexp.Regexp
+	spanKinds         []ptrace.SpanKind
+	statusCodes       []ptrace.StatusCode
+	attributePatterns map[string]*regexp.Regexp
+	dropped           selfstat.Stat
+}
+
+func (f *SpanFilter) init() error {
+	if f.Name == "" {
+		return fmt.Errorf("filter name is required")
+	}
+	if f.Action == "" {
+		f.Action = filterActionExclude
+	}
+	if f.Action != filterActionInclude && f.Action != filterActionExclude {
+		return fmt.Errorf("filter %s: invalid action %q", f.Name, f.Action)
+	}
+	if f.DropTrace && f.Action != filterActionExclude {
+		return fmt.Errorf("filter %s: drop_trace needs the exclude action", f.Name)
+	}
+	for _, name := range f.SpanNames {
+		re, err := regexp.Compile(name)
+		if err != nil {
+			return fmt.Errorf("filter %s: invalid span name pattern %q: %w", f.Name, name, err)
+		}
+		f.spanNames = append(f.spanNames, re)
+	}
+	for _, kind := range f.SpanKinds {
+		spanKind, err := spanKindFromConfig(kind)
+		if err != nil {
+			return fmt.Errorf("filter %s: %w", f.Name, err)
+		}
+		f.spanKinds = append(f.spanKinds, spanKind)
+	}
+	for _, code := range f.StatusCodes {
+		statusCode, err := statusCodeFromString(code)
+		if err != nil {
+			return fmt.Errorf("filter %s: %w", f.Name, err)
+		}
+		f.statusCodes = append(f.statusCodes, statusCode)
+	}
+	f.attributePatterns = make(map[string]*regexp.Regexp, len(f.AttributePatterns))
+	for key, pattern := range f.AttributePatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("filter %s: invalid pattern %q for attribute %s: %w", f.Name, pattern, key, err)
+		}
+		f.attributePatterns[key] = re
+	}
+	f.dropped = selfstat.Register("oteltrace", "filter_dropped_spans", map[string]string{"filter": f.Name})
+	return nil
+}
+
+// statusCodeFromString accepts status codes with or without the
+// STATUS_CODE_ prefix, in any case.
+func statusCodeFromString(code string) (ptrace.StatusCode, error) {
+	switch strings.TrimPrefix(strings.ToUpper(code), "STATUS_CODE_") {
+	case "UNSET":
+		return ptrace.StatusCodeUnset, nil
+	case "OK":
+		return ptrace.StatusCodeOk, nil
+	case "ERROR":
+		return ptrace.StatusCodeError, nil
+	default:
+		return ptrace.StatusCodeUnset, fmt.Errorf("invalid status code %q", code)
+	}
+}
+
+func (f *SpanFilter) matches(resource pcommon.Resource, span ptrace.Span) bool {
+	if len(f.spanNames) > 0 && !matchesAny(f.spanNames, span.Name()) {
+		return false
+	}
+	if len(f.Services) > 0 {
+		serviceName := ""
+		if v, ok := resource.Attributes().Get(semconv.AttributeServiceName); ok {
+			serviceName = v.AsString()
+		}
+		if !slices.Contains(f.Services, serviceName) {
+			return false
+		}
+	}
+	if len(f.spanKinds) > 0 && !slices.Contains(f.spanKinds, span.Kind()) {
+		return false
+	}
+	if len(f.statusCodes) > 0 && !slices.Contains(f.statusCodes, span.Status().Code()) {
+		return false
+	}
+	for key, value := range f.Attributes {
+		v, ok := span.Attributes().Get(key)
+		if !ok || v.AsString() != value {
+			return false
+		}
+	}
+	for key, re := range f.attributePatterns {
+		v, ok := span.Attributes().Get(key)
+		if !ok || !re.MatchString(v.AsString()) {
+			return false
+		}
+	}
+	if f.MinDuration > 0 {
+		if span.EndTimestamp() < span.StartTimestamp() ||
+			time.Duration(span.EndTimestamp()-span.StartTimestamp()) < time.Duration(f.MinDuration) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterSpans removes the filtered spans from the batch, along with the
+// traces left empty. Only the spans of the batch are seen, so a dropped
+// trace's spans written later are still exported.
+func (o *OtelTrace) filterSpans(traceBatch map[string]ptrace.Traces) {
+	droppedTraces := map[pcommon.TraceID]*SpanFilter{}
+	for _, td := range traceBatch {
+		forEachSpan(td, func(resource pcommon.Resource, span ptrace.Span) {
+			if !span.ParentSpanID().IsEmpty() {
+				return
+			}
+			for _, f := range o.Filters {
+				if f.DropTrace && f.matches(resource, span) {
+					droppedTraces[span.TraceID()] = f
+					return
+				}
+			}
+		})
+	}
+
+	for key, td := range traceBatch {
+		td.ResourceSpans().RemoveIf(func(rs ptrace.ResourceSpans) bool {
+			rs.ScopeSpans().RemoveIf(func(ss ptrace.ScopeSpans) bool {
+				ss.Spans().RemoveIf(func(span ptrace.Span) bool {
+					if f, ok := droppedTraces[span.TraceID()]; ok {
+						f.dropped.Incr(1)
+						return true
+					}
+					if f := o.filterFor(rs.Resource(), span); f != nil {
+						f.dropped.Incr(1)
+						return true
+					}
+					return false
+				})
+				return ss.Spans().Len() == 0
+			})
+			return rs.ScopeSpans().Len() == 0
+		})
+		if td.SpanCount() == 0 {
+			o.Log.Debugf("dropping filtered trace %s", key)
+			delete(traceBatch, key)
+		}
+	}
+}
+
+// filterFor returns the filter the span is dropped by, or nil when it's kept.
+// A span that no include filter matches is counted against the first one.
+func (o *OtelTrace) filterFor(resource pcommon.Resource, span ptrace.Span) *SpanFilter {
+	var include *SpanFilter
+	included := false
+	for _, f := range o.Filters {
+		switch f.Action {
+		case filterActionExclude:
+			if f.matches(resource, span) {
+				return f
+			}
+		case filterActionInclude:
+			if include == nil {
+				include = f
+			}
+			if !included && f.matches(resource, span) {
+				included = true
+			}
+		}
+	}
+	if include != nil && !included {
+		return include
+	}
+	return nil
+}
+
+func forEachSpan(td ptrace.Traces, fn func(resource pcommon.Resource, span ptrace.Span)) {
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			spans := rs.ScopeSpans().At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				fn(rs.Resource(), spans.At(k))
+			}
+		}
+	}
+}
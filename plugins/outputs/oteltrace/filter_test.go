@@ -0,0 +1,122 @@
+package oteltrace_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/catherinetcai/telegraf-execd-otel/plugins/outputs/oteltrace"
+	influxcommon "github.com/influxdata/influxdb-observability/common"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/selfstat"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	semconv "go.opentelemetry.io/collector/semconv/v1.16.0"
+)
+
+// filterSpan returns a span of trace traceID lasting duration, a child of
+// parentSpanID unless it's 0.
+func filterSpan(traceID, spanID, parentSpanID int, name string, duration time.Duration, statusCode, attributes string) telegraf.Metric {
+	m := generateTraceAsMetric()
+	m.AddTag(influxcommon.AttributeTraceID, fmt.Sprintf("%032x", traceID))
+	m.AddTag(influxcommon.AttributeSpanID, fmt.Sprintf("%016x", spanID))
+	if parentSpanID != 0 {
+		m.AddField(influxcommon.AttributeParentSpanID, fmt.Sprintf("%016x", parentSpanID))
+	}
+	m.AddField(influxcommon.AttributeSpanName, name)
+	m.AddField(influxcommon.AttributeEndTimeUnixNano, m.Time().Add(duration).UnixNano())
+	m.AddField(semconv.OtelStatusCode, statusCode)
+	m.AddField(influxcommon.AttributeAttributes, attributes)
+	return m
+}
+
+func spanNames(td ptrace.Traces) []string {
+	var names []string
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		spans := td.ResourceSpans().At(i).ScopeSpans().At(0).Spans()
+		for j := 0; j < spans.Len(); j++ {
+			names = append(names, spans.At(j).Name())
+		}
+	}
+	return names
+}
+
+func TestOtelTraceFilter(t *testing.T) {
+	ot := &oteltrace.OtelTrace{
+		Filters: []*oteltrace.SpanFilter{
+			{
+				Name:      "health checks",
+				SpanNames: []string{"^GET /(healthz|ready)$"},
+				Services:  []string{"checkout"},
+				SpanKinds: []string{"server"},
+				DropTrace: true,
+			},
+			{
+				Name:              "probes",
+				AttributePatterns: map[string]string{"user_agent.original": "^kube-probe/"},
+			},
+			{
+				Name:        "fast successes",
+				StatusCodes: []string{"ok"},
+				Attributes:  map[string]string{"db.system": "redis"},
+			},
+			{
+				Name:        "slow or failed",
+				Action:      "include",
+				MinDuration: config.Duration(10 * time.Millisecond),
+			},
+			{
+				Name:        "failed",
+				Action:      "include",
+				StatusCodes: []string{"STATUS_CODE_ERROR"},
+			},
+		},
+	}
+	dropped := func(name string) int64 {
+		return selfstat.Register("oteltrace", "filter_dropped_spans", map[string]string{"filter": name}).Get()
+	}
+	before := map[string]int64{}
+	for _, f := range ot.Filters {
+		before[f.Name] = dropped(f.Name)
+	}
+
+	td := writeTraces(t, ot,
+		filterSpan(1, 1, 0, "GET /healthz", 20*time.Millisecond, "STATUS_CODE_OK", `{"service.name":"checkout"}`),
+		filterSpan(1, 2, 1, "SELECT", 20*time.Millisecond, "STATUS_CODE_OK", `{"service.name":"checkout"}`),
+		filterSpan(2, 3, 0, "GET /metrics", 20*time.Millisecond, "STATUS_CODE_OK", `{"service.name":"checkout","user_agent.original":"kube-probe/1.29"}`),
+		filterSpan(3, 4, 0, "POST /pay", 20*time.Millisecond, "STATUS_CODE_OK", `{"service.name":"checkout"}`),
+		filterSpan(3, 5, 4, "GET", 20*time.Millisecond, "STATUS_CODE_OK", `{"service.name":"checkout","db.system":"redis"}`),
+		filterSpan(3, 6, 4, "SET", time.Millisecond, "STATUS_CODE_ERROR", `{"service.name":"checkout","db.system":"redis"}`),
+		filterSpan(3, 7, 4, "SELECT", time.Millisecond, "STATUS_CODE_OK", `{"service.name":"checkout"}`),
+	)
+	assert.ElementsMatch(t, []string{"POST /pay", "SET"}, spanNames(td))
+
+	for name, count := range map[string]int64{
+		"health checks":  2,
+		"probes":         1,
+		"fast successes": 1,
+		"slow or failed": 1,
+		"failed":         0,
+	} {
+		assert.Equal(t, count, dropped(name)-before[name], name)
+	}
+}
+
+func TestOtelTraceFilterInit(t *testing.T) {
+	ot := &oteltrace.OtelTrace{Filters: []*oteltrace.SpanFilter{{}}}
+	require.ErrorContains(t, ot.Init(), "filter name is required")
+
+	ot = &oteltrace.OtelTrace{Filters: []*oteltrace.SpanFilter{{Name: "a", StatusCodes: []string{"failed"}}}}
+	require.ErrorContains(t, ot.Init(), `filter a: invalid status code "failed"`)
+
+	ot = &oteltrace.OtelTrace{Filters: []*oteltrace.SpanFilter{{Name: "a", SpanKinds: []string{"SPAN_KIND_SERVER", "sever"}}}}
+	require.ErrorContains(t, ot.Init(), `filter a: invalid span kind "sever"`)
+
+	ot = &oteltrace.OtelTrace{Filters: []*oteltrace.SpanFilter{{Name: "a", Action: "include", DropTrace: true}}}
+	require.ErrorContains(t, ot.Init(), "filter a: drop_trace needs the exclude action")
+
+	ot = &oteltrace.OtelTrace{Filters: []*oteltrace.SpanFilter{{Name: "a"}, {Name: "a"}}}
+	require.ErrorContains(t, ot.Init(), "duplicate filter name a")
+}
@@ -0,0 +1,98 @@
+package oteltrace
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// metricsGenerator derives metrics from the exported spans.
+type metricsGenerator interface {
+	add(td ptrace.Traces)
+	// metrics returns the current state, or false when there's nothing to
+	// send yet.
+	metrics(now time.Time) (pmetric.Metrics, bool)
+}
+
+// generator runs a metricsGenerator, exporting its metrics every interval to
+// its own endpoint, or like the metrics option when it has none.
+type generator struct {
+	name           string
+	interval       time.Duration
+	serviceAddress string
+	metricsGenerator
+
+	export func(context.Context, pmetric.Metrics) error
+	conn   *grpc.ClientConn
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func (o *OtelTrace) startGenerator(g *generator) error {
+	g.export = o.exportMetrics
+	if g.serviceAddress != "" {
+		conn, err := o.dial(g.serviceAddress, o.tlsConfig, o.tokenSource)
+		if err != nil {
+			return err
+		}
+		g.conn = conn
+		client := pmetricotlp.NewGRPCClient(conn)
+		g.export = func(ctx context.Context, md pmetric.Metrics) error {
+			if len(o.Headers) > 0 {
+				ctx = metadata.NewOutgoingContext(ctx, metadata.New(o.Headers))
+			}
+			_, err := client.Export(ctx, pmetricotlp.NewExportRequestFromMetrics(md))
+			return err
+		}
+	}
+
+	g.done = make(chan struct{})
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		ticker := time.NewTicker(g.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-g.done:
+				return
+			case <-ticker.C:
+				o.flushGenerator(g)
+			}
+		}
+	}()
+	return nil
+}
+
+// stopGenerator stops the export loop and sends the final state.
+func (o *OtelTrace) stopGenerator(g *generator) error {
+	if g.done == nil {
+		return nil
+	}
+	close(g.done)
+	g.wg.Wait()
+	g.done = nil
+	o.flushGenerator(g)
+	if g.conn != nil {
+		return g.conn.Close()
+	}
+	return nil
+}
+
+func (o *OtelTrace) flushGenerator(g *generator) {
+	md, ok := g.metrics(time.Now())
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(defaultExporterTimeout))
+	defer cancel()
+	if err := g.export(ctx, md); err != nil {
+		o.Log.Errorf("failed to export %s: %s", g.name, err)
+	}
+}
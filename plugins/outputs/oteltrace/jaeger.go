@@ -0,0 +1,174 @@
+package oteltrace
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	gogoproto "github.com/gogo/protobuf/proto"
+	"github.com/jaegertracing/jaeger/model"
+	"github.com/jaegertracing/jaeger/proto-gen/api_v2"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	semconv "go.opentelemetry.io/collector/semconv/v1.16.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+var _ encoding.Codec = jaegerCodec{}
+
+// jaegerExporter sends spans to a Jaeger collector's gRPC api_v2 endpoint.
+type jaegerExporter struct {
+	client  api_v2.CollectorServiceClient
+	conn    *grpc.ClientConn
+	headers map[string]string
+}
+
+func (e *jaegerExporter) Export(ctx context.Context, td ptrace.Traces) error {
+	ctx = withHeaders(ctx, e.headers)
+	for _, batch := range tracesToJaeger(td) {
+		if _, err := e.client.PostSpans(ctx, &api_v2.PostSpansRequest{Batch: batch}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *jaegerExporter) Close() error {
+	return e.conn.Close()
+}
+
+// jaegerCodec marshals Jaeger's gogo generated messages, which grpc's default
+// codec can't handle because of their custom ID types.
+type jaegerCodec struct{}
+
+func (jaegerCodec) Marshal(v any) ([]byte, error) {
+	msg, ok := v.(gogoproto.Message)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type %T", v)
+	}
+	return gogoproto.Marshal(msg)
+}
+
+func (jaegerCodec) Unmarshal(data []byte, v any) error {
+	msg, ok := v.(gogoproto.Message)
+	if !ok {
+		return fmt.Errorf("unexpected message type %T", v)
+	}
+	return gogoproto.Unmarshal(data, msg)
+}
+
+func (jaegerCodec) Name() string {
+	return "proto"
+}
+
+// tracesToJaeger builds one batch per resource, with the resource attributes
+// as the batch's process tags.
+func tracesToJaeger(td ptrace.Traces) []model.Batch {
+	batches := make([]model.Batch, 0, td.ResourceSpans().Len())
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		batch := model.Batch{Process: jaegerProcess(rs.Resource())}
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			ss := rs.ScopeSpans().At(j)
+			for k := 0; k < ss.Spans().Len(); k++ {
+				batch.Spans = append(batch.Spans, spanToJaeger(ss.Spans().At(k), ss.Scope()))
+			}
+		}
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+func jaegerProcess(resource pcommon.Resource) *model.Process {
+	process := &model.Process{}
+	resource.Attributes().Range(func(k string, v pcommon.Value) bool {
+		if k == semconv.AttributeServiceName {
+			process.ServiceName = v.AsString()
+			return true
+		}
+		process.Tags = append(process.Tags, jaegerKeyValue(k, v))
+		return true
+	})
+	return process
+}
+
+func spanToJaeger(span ptrace.Span, scope pcommon.InstrumentationScope) *model.Span {
+	traceID := jaegerTraceID(span.TraceID())
+	jSpan := &model.Span{
+		TraceID:       traceID,
+		SpanID:        jaegerSpanID(span.SpanID()),
+		OperationName: span.Name(),
+		StartTime:     span.StartTimestamp().AsTime(),
+	}
+	if span.EndTimestamp() > span.StartTimestamp() {
+		jSpan.Duration = time.Duration(span.EndTimestamp() - span.StartTimestamp())
+	}
+
+	if !span.ParentSpanID().IsEmpty() {
+		jSpan.References = append(jSpan.References, model.NewChildOfRef(traceID, jaegerSpanID(span.ParentSpanID())))
+	}
+	for i := 0; i < span.Links().Len(); i++ {
+		link := span.Links().At(i)
+		jSpan.References = append(jSpan.References, model.NewFollowsFromRef(jaegerTraceID(link.TraceID()), jaegerSpanID(link.SpanID())))
+	}
+
+	span.Attributes().Range(func(k string, v pcommon.Value) bool {
+		jSpan.Tags = append(jSpan.Tags, jaegerKeyValue(k, v))
+		return true
+	})
+	if sk := trace.SpanKind(span.Kind()); sk != trace.SpanKindUnspecified && sk != trace.SpanKindInternal {
+		jSpan.Tags = append(jSpan.Tags, model.String("span.kind", sk.String()))
+	}
+	if scope.Name() != "" {
+		jSpan.Tags = append(jSpan.Tags, model.String(semconv.AttributeOtelLibraryName, scope.Name()))
+	}
+	switch span.Status().Code() {
+	case ptrace.StatusCodeError:
+		jSpan.Tags = append(jSpan.Tags, model.String(semconv.AttributeOtelStatusCode, "ERROR"), model.Bool("error", true))
+	case ptrace.StatusCodeOk:
+		jSpan.Tags = append(jSpan.Tags, model.String(semconv.AttributeOtelStatusCode, "OK"))
+	}
+	if span.Status().Message() != "" {
+		jSpan.Tags = append(jSpan.Tags, model.String(semconv.AttributeOtelStatusDescription, span.Status().Message()))
+	}
+
+	for i := 0; i < span.Events().Len(); i++ {
+		event := span.Events().At(i)
+		log := model.Log{
+			Timestamp: event.Timestamp().AsTime(),
+			Fields:    []model.KeyValue{model.String("event", event.Name())},
+		}
+		event.Attributes().Range(func(k string, v pcommon.Value) bool {
+			log.Fields = append(log.Fields, jaegerKeyValue(k, v))
+			return true
+		})
+		jSpan.Logs = append(jSpan.Logs, log)
+	}
+	return jSpan
+}
+
+func jaegerKeyValue(k string, v pcommon.Value) model.KeyValue {
+	switch v.Type() {
+	case pcommon.ValueTypeBool:
+		return model.Bool(k, v.Bool())
+	case pcommon.ValueTypeInt:
+		return model.Int64(k, v.Int())
+	case pcommon.ValueTypeDouble:
+		return model.Float64(k, v.Double())
+	case pcommon.ValueTypeBytes:
+		return model.Binary(k, v.Bytes().AsRaw())
+	default:
+		return model.String(k, v.AsString())
+	}
+}
+
+func jaegerTraceID(traceID pcommon.TraceID) model.TraceID {
+	return model.NewTraceID(binary.BigEndian.Uint64(traceID[:8]), binary.BigEndian.Uint64(traceID[8:]))
+}
+
+func jaegerSpanID(spanID pcommon.SpanID) model.SpanID {
+	return model.NewSpanID(binary.BigEndian.Uint64(spanID[:]))
+}
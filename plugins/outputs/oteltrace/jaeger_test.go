@@ -0,0 +1,103 @@
+package oteltrace_test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/catherinetcai/telegraf-execd-otel/plugins/outputs/oteltrace"
+	gogoproto "github.com/gogo/protobuf/proto"
+	influxcommon "github.com/influxdata/influxdb-observability/common"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/jaegertracing/jaeger/model"
+	"github.com/jaegertracing/jaeger/proto-gen/api_v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// gogoCodec lets the fake collector decode Jaeger's gogo generated messages.
+type gogoCodec struct{}
+
+func (gogoCodec) Marshal(v any) ([]byte, error) { return gogoproto.Marshal(v.(gogoproto.Message)) }
+
+func (gogoCodec) Unmarshal(data []byte, v any) error {
+	return gogoproto.Unmarshal(data, v.(gogoproto.Message))
+}
+
+func (gogoCodec) Name() string { return "proto" }
+
+type fakeJaegerCollector struct {
+	mu       sync.Mutex
+	batches  []model.Batch
+	metadata []metadata.MD
+}
+
+func (f *fakeJaegerCollector) PostSpans(ctx context.Context, r *api_v2.PostSpansRequest) (*api_v2.PostSpansResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	md, _ := metadata.FromIncomingContext(ctx)
+	f.metadata = append(f.metadata, md)
+	f.batches = append(f.batches, r.Batch)
+	return &api_v2.PostSpansResponse{}, nil
+}
+
+func startJaegerCollector(t *testing.T, collector *fakeJaegerCollector) string {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.ForceServerCodec(gogoCodec{}))
+	api_v2.RegisterCollectorServiceServer(s, collector)
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, s.Serve(lis))
+	}()
+	t.Cleanup(func() {
+		s.Stop()
+		wg.Wait()
+	})
+	return lis.Addr().String()
+}
+
+func TestOtelTraceJaeger(t *testing.T) {
+	collector := &fakeJaegerCollector{}
+	ot := &oteltrace.OtelTrace{
+		Exporters: []*oteltrace.ExporterConfig{
+			{
+				Name:           "jaeger",
+				Type:           "jaeger",
+				ServiceAddress: startJaegerCollector(t, collector),
+				Headers:        map[string]string{"X-Tenant": "legacy"},
+			},
+		},
+		Log: &testutil.Logger{},
+	}
+	require.NoError(t, ot.Init())
+	require.NoError(t, ot.Connect())
+	defer ot.Close()
+
+	m := generateTraceAsMetric()
+	m.AddField(influxcommon.AttributeParentSpanID, "0000000000000001")
+	m.AddField(influxcommon.AttributeEndTimeUnixNano, m.Time().Add(2*1e6).UnixNano())
+	m.AddField(influxcommon.AttributeAttributes, `{"service.name":"frontend","http.method":"GET"}`)
+	require.NoError(t, ot.Write([]telegraf.Metric{m}))
+
+	require.Len(t, collector.batches, 1)
+	assert.Equal(t, []string{"legacy"}, collector.metadata[0].Get("X-Tenant"))
+	batch := collector.batches[0]
+	assert.Equal(t, "frontend", batch.Process.ServiceName)
+	assert.Contains(t, batch.Process.Tags, model.String("http.method", "GET"))
+	require.Len(t, batch.Spans, 1)
+	span := batch.Spans[0]
+	assert.Equal(t, model.NewTraceID(0, 1), span.TraceID)
+	assert.Equal(t, model.NewSpanID(10), span.SpanID)
+	assert.Equal(t, "fakespan", span.OperationName)
+	assert.Equal(t, m.Time(), span.StartTime.UTC())
+	assert.Equal(t, int64(2e6), span.Duration.Nanoseconds())
+	assert.Equal(t, []model.SpanRef{model.NewChildOfRef(model.NewTraceID(0, 1), model.NewSpanID(1))}, span.References)
+	assert.Contains(t, span.Tags, model.String("span.kind", "server"))
+}
@@ -0,0 +1,171 @@
+package oteltrace
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+
+	"github.com/IBM/sarama"
+	"github.com/influxdata/telegraf/plugins/common/kafka"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+const (
+	kafkaEncodingProto = "otlp_proto"
+	kafkaEncodingJSON  = "otlp_json"
+
+	// Same default as the collector's kafka exporter
+	defaultKafkaTopic = "otlp_spans"
+)
+
+// KafkaExporterConfig holds the settings of exporters with type "kafka".
+type KafkaExporterConfig struct {
+	Brokers          []string `toml:"brokers"`
+	Topic            string   `toml:"topic"`
+	Encoding         string   `toml:"encoding"`
+	KafkaVersion     string   `toml:"kafka_version"`
+	ClientID         string   `toml:"client_id"`
+	RequiredAcks     *int     `toml:"required_acks"`
+	ProducerMaxRetry *int     `toml:"producer_max_retry"`
+	kafka.SASLAuth
+}
+
+func (c *KafkaExporterConfig) init() error {
+	if len(c.Brokers) == 0 {
+		return fmt.Errorf("at least one broker is required")
+	}
+	if c.Topic == "" {
+		c.Topic = defaultKafkaTopic
+	}
+	switch c.Encoding {
+	case "":
+		c.Encoding = kafkaEncodingProto
+	case kafkaEncodingProto, kafkaEncodingJSON:
+	default:
+		return fmt.Errorf("unknown kafka encoding %q", c.Encoding)
+	}
+	return nil
+}
+
+func (c *KafkaExporterConfig) saramaConfig(tlsConfig *tls.Config) (*sarama.Config, error) {
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+	// Messages are keyed by trace ID so that a trace stays on one partition
+	config.Producer.Partitioner = sarama.NewHashPartitioner
+	if c.RequiredAcks != nil {
+		config.Producer.RequiredAcks = sarama.RequiredAcks(*c.RequiredAcks)
+	}
+	if c.ProducerMaxRetry != nil {
+		config.Producer.Retry.Max = *c.ProducerMaxRetry
+	}
+	if c.KafkaVersion != "" {
+		version, err := sarama.ParseKafkaVersion(c.KafkaVersion)
+		if err != nil {
+			return nil, err
+		}
+		config.Version = version
+	}
+	config.ClientID = "telegraf-execd-otel"
+	if c.ClientID != "" {
+		config.ClientID = c.ClientID
+	}
+	if tlsConfig != nil {
+		config.Net.TLS.Enable = true
+		config.Net.TLS.Config = tlsConfig
+	}
+	if err := c.SASLAuth.SetSASLConfig(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// kafkaExporter publishes one message per trace to a Kafka topic.
+type kafkaExporter struct {
+	topic    string
+	encoding string
+	producer sarama.SyncProducer
+}
+
+func newKafkaExporter(c *ExporterConfig) (*kafkaExporter, error) {
+	config, err := c.KafkaExporterConfig.saramaConfig(c.tlsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("exporter %s: %w", c.Name, err)
+	}
+	producer, err := sarama.NewSyncProducer(c.Brokers, config)
+	if err != nil {
+		return nil, fmt.Errorf("exporter %s: failed to create kafka producer: %w", c.Name, err)
+	}
+	return &kafkaExporter{
+		topic:    c.Topic,
+		encoding: c.Encoding,
+		producer: producer,
+	}, nil
+}
+
+func (e *kafkaExporter) Export(_ context.Context, td ptrace.Traces) error {
+	byTrace := splitByTraceID(td)
+	messages := make([]*sarama.ProducerMessage, 0, len(byTrace))
+	for traceID, traces := range byTrace {
+		var value []byte
+		var err error
+		switch e.encoding {
+		case kafkaEncodingProto:
+			value, err = (&ptrace.ProtoMarshaler{}).MarshalTraces(traces)
+		case kafkaEncodingJSON:
+			value, err = (&ptrace.JSONMarshaler{}).MarshalTraces(traces)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to marshal traces: %w", err)
+		}
+		messages = append(messages, &sarama.ProducerMessage{
+			Topic: e.topic,
+			Key:   sarama.StringEncoder(traceID.String()),
+			Value: sarama.ByteEncoder(value),
+		})
+	}
+	return e.producer.SendMessages(messages)
+}
+
+func (e *kafkaExporter) Close() error {
+	return e.producer.Close()
+}
+
+// splitByTraceID splits td into one ptrace.Traces per trace ID, keeping each
+// span's resource and scope.
+func splitByTraceID(td ptrace.Traces) map[pcommon.TraceID]ptrace.Traces {
+	byTrace := map[pcommon.TraceID]ptrace.Traces{}
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		resources := map[pcommon.TraceID]ptrace.ResourceSpans{}
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			ss := rs.ScopeSpans().At(j)
+			scopes := map[pcommon.TraceID]ptrace.ScopeSpans{}
+			for k := 0; k < ss.Spans().Len(); k++ {
+				span := ss.Spans().At(k)
+				traceID := span.TraceID()
+				scope, ok := scopes[traceID]
+				if !ok {
+					resource, ok := resources[traceID]
+					if !ok {
+						traces, ok := byTrace[traceID]
+						if !ok {
+							traces = ptrace.NewTraces()
+							byTrace[traceID] = traces
+						}
+						resource = traces.ResourceSpans().AppendEmpty()
+						rs.Resource().CopyTo(resource.Resource())
+						resource.SetSchemaUrl(rs.SchemaUrl())
+						resources[traceID] = resource
+					}
+					scope = resource.ScopeSpans().AppendEmpty()
+					ss.Scope().CopyTo(scope.Scope())
+					scope.SetSchemaUrl(ss.SchemaUrl())
+					scopes[traceID] = scope
+				}
+				span.CopyTo(scope.Spans().AppendEmpty())
+			}
+		}
+	}
+	return byTrace
+}
@@ -0,0 +1,97 @@
+package oteltrace_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/catherinetcai/telegraf-execd-otel/plugins/outputs/oteltrace"
+	influxcommon "github.com/influxdata/influxdb-observability/common"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func produceRequests(broker *sarama.MockBroker) int {
+	count := 0
+	for _, rr := range broker.History() {
+		if _, ok := rr.Request.(*sarama.ProduceRequest); ok {
+			count++
+		}
+	}
+	return count
+}
+
+func TestOtelTraceKafka(t *testing.T) {
+	// Each partition is led by its own fake broker so we can tell which
+	// partition a trace was sent to.
+	leader0 := sarama.NewMockBroker(t, 1)
+	defer leader0.Close()
+	leader1 := sarama.NewMockBroker(t, 2)
+	defer leader1.Close()
+	for _, broker := range []*sarama.MockBroker{leader0, leader1} {
+		broker.SetHandlerByMap(map[string]sarama.MockResponse{
+			"MetadataRequest": sarama.NewMockMetadataResponse(t).
+				SetBroker(leader0.Addr(), leader0.BrokerID()).
+				SetBroker(leader1.Addr(), leader1.BrokerID()).
+				SetLeader("otlp_spans", 0, leader0.BrokerID()).
+				SetLeader("otlp_spans", 1, leader1.BrokerID()),
+			"ProduceRequest": sarama.NewMockProduceResponse(t),
+		})
+	}
+
+	// Find a trace that hashes to partition 1, trace 1 is on partition 0
+	partitioner := sarama.NewHashPartitioner("otlp_spans")
+	partitionOf := func(traceID string) int32 {
+		p, err := partitioner.Partition(&sarama.ProducerMessage{Key: sarama.StringEncoder(traceID)}, 2)
+		require.NoError(t, err)
+		return p
+	}
+	trace1 := fmt.Sprintf("%032x", 1)
+	require.Equal(t, int32(0), partitionOf(trace1))
+	trace2 := fmt.Sprintf("%032x", 2)
+	for i := 3; partitionOf(trace2) != 1; i++ {
+		trace2 = fmt.Sprintf("%032x", i)
+	}
+
+	ot := &oteltrace.OtelTrace{
+		Exporters: []*oteltrace.ExporterConfig{
+			{
+				Name: "kafka",
+				Type: "kafka",
+				KafkaExporterConfig: oteltrace.KafkaExporterConfig{
+					Brokers: []string{leader0.Addr()},
+				},
+			},
+		},
+		Log: &testutil.Logger{},
+	}
+	require.NoError(t, ot.Init())
+	require.NoError(t, ot.Connect())
+	defer ot.Close()
+
+	other := generateTraceAsMetric()
+	other.AddTag(influxcommon.AttributeTraceID, trace2)
+	require.NoError(t, ot.Write([]telegraf.Metric{generateTraceAsMetric()}))
+	require.NoError(t, ot.Write([]telegraf.Metric{generateTraceAsMetric()}))
+	require.NoError(t, ot.Write([]telegraf.Metric{other}))
+	assert.Equal(t, 2, produceRequests(leader0))
+	assert.Equal(t, 1, produceRequests(leader1))
+}
+
+func TestOtelTraceKafkaInit(t *testing.T) {
+	ot := &oteltrace.OtelTrace{
+		Exporters: []*oteltrace.ExporterConfig{
+			{
+				Name: "kafka",
+				Type: "kafka",
+				KafkaExporterConfig: oteltrace.KafkaExporterConfig{
+					Brokers:  []string{"localhost:9092"},
+					Encoding: "avro",
+				},
+			},
+		},
+	}
+	assert.ErrorContains(t, ot.Init(), "unknown kafka encoding")
+}
@@ -0,0 +1,147 @@
+package oteltrace
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// SpanLimits caps what a converted span can carry, like the SDKs' span limits
+// do. Whatever is dropped is added to the span's, event's or link's dropped
+// count on top of what otel2influx recorded. Zero means unlimited.
+type SpanLimits struct {
+	AttributeCount       int `toml:"attribute_count"`
+	AttributeValueLength int `toml:"attribute_value_length"`
+	EventCount           int `toml:"event_count"`
+	LinkCount            int `toml:"link_count"`
+	EventAttributeCount  int `toml:"event_attribute_count"`
+	LinkAttributeCount   int `toml:"link_attribute_count"`
+}
+
+func (l *SpanLimits) init() error {
+	for name, limit := range map[string]int{
+		"attribute_count":        l.AttributeCount,
+		"attribute_value_length": l.AttributeValueLength,
+		"event_count":            l.EventCount,
+		"link_count":             l.LinkCount,
+		"event_attribute_count":  l.EventAttributeCount,
+		"link_attribute_count":   l.LinkAttributeCount,
+	} {
+		if limit < 0 {
+			return fmt.Errorf("span limit %s can't be negative", name)
+		}
+	}
+	return nil
+}
+
+// limitSpans enforces the limits on every span of the batch, and on the
+// resources, which are built from the span attributes.
+func (o *OtelTrace) limitSpans(traceBatch map[string]ptrace.Traces) {
+	for _, td := range traceBatch {
+		for i := 0; i < td.ResourceSpans().Len(); i++ {
+			resource := td.ResourceSpans().At(i).Resource()
+			dropped := o.Limits.limitAttributes(resource.Attributes(), o.Limits.AttributeCount)
+			resource.SetDroppedAttributesCount(resource.DroppedAttributesCount() + dropped)
+		}
+		forEachSpan(td, func(_ pcommon.Resource, span ptrace.Span) {
+			o.Limits.limitSpan(span)
+		})
+	}
+}
+
+func (l *SpanLimits) limitSpan(span ptrace.Span) {
+	dropped := l.limitAttributes(span.Attributes(), l.AttributeCount)
+	span.SetDroppedAttributesCount(span.DroppedAttributesCount() + dropped)
+
+	// The oldest events and links are dropped first, as the SDKs do
+	if events := span.Events(); l.EventCount > 0 && events.Len() > l.EventCount {
+		drop := events.Len() - l.EventCount
+		i := 0
+		events.RemoveIf(func(ptrace.SpanEvent) bool {
+			i++
+			return i <= drop
+		})
+		span.SetDroppedEventsCount(span.DroppedEventsCount() + uint32(drop))
+	}
+	for i := 0; i < span.Events().Len(); i++ {
+		event := span.Events().At(i)
+		dropped := l.limitAttributes(event.Attributes(), l.EventAttributeCount)
+		event.SetDroppedAttributesCount(event.DroppedAttributesCount() + dropped)
+	}
+
+	if links := span.Links(); l.LinkCount > 0 && links.Len() > l.LinkCount {
+		drop := links.Len() - l.LinkCount
+		i := 0
+		links.RemoveIf(func(ptrace.SpanLink) bool {
+			i++
+			return i <= drop
+		})
+		span.SetDroppedLinksCount(span.DroppedLinksCount() + uint32(drop))
+	}
+	for i := 0; i < span.Links().Len(); i++ {
+		link := span.Links().At(i)
+		dropped := l.limitAttributes(link.Attributes(), l.LinkAttributeCount)
+		link.SetDroppedAttributesCount(link.DroppedAttributesCount() + dropped)
+	}
+}
+
+// limitAttributes truncates the values of attributes and drops the keys past
+// count, returning how many were dropped. The attributes decoded from JSON
+// have no order, so the keys are kept in sorted order for the same span to
+// always keep the same ones.
+func (l *SpanLimits) limitAttributes(attributes pcommon.Map, count int) uint32 {
+	var dropped uint32
+	if count > 0 && attributes.Len() > count {
+		keys := make([]string, 0, attributes.Len())
+		attributes.Range(func(key string, _ pcommon.Value) bool {
+			keys = append(keys, key)
+			return true
+		})
+		sort.Strings(keys)
+		keep := make(map[string]bool, count)
+		for _, key := range keys[:count] {
+			keep[key] = true
+		}
+		attributes.RemoveIf(func(key string, _ pcommon.Value) bool {
+			if keep[key] {
+				return false
+			}
+			dropped++
+			return true
+		})
+	}
+	if l.AttributeValueLength > 0 {
+		attributes.Range(func(_ string, value pcommon.Value) bool {
+			truncateValue(value, l.AttributeValueLength)
+			return true
+		})
+	}
+	return dropped
+}
+
+// truncateValue truncates strings, and the strings of arrays, to length
+// characters.
+func truncateValue(value pcommon.Value, length int) {
+	switch value.Type() {
+	case pcommon.ValueTypeStr:
+		s := value.Str()
+		if utf8.RuneCountInString(s) <= length {
+			return
+		}
+		n := 0
+		for i := range s {
+			if n == length {
+				value.SetStr(s[:i])
+				return
+			}
+			n++
+		}
+	case pcommon.ValueTypeSlice:
+		for i := 0; i < value.Slice().Len(); i++ {
+			truncateValue(value.Slice().At(i), length)
+		}
+	}
+}
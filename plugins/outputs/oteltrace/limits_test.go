@@ -0,0 +1,64 @@
+package oteltrace_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/catherinetcai/telegraf-execd-otel/plugins/outputs/oteltrace"
+	influxcommon "github.com/influxdata/influxdb-observability/common"
+	"github.com/influxdata/telegraf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOtelTraceLimits(t *testing.T) {
+	ot := &oteltrace.OtelTrace{
+		LogSpanEvents: true,
+		Limits: &oteltrace.SpanLimits{
+			AttributeCount:       3,
+			AttributeValueLength: 4,
+			EventCount:           2,
+			EventAttributeCount:  1,
+		},
+	}
+	span := attributesSpan("pay", `{"a":"héllo wörld","b":["abcdef","ab"],"c":12345,"d":true,"e":"x"}`)
+	span.AddField(influxcommon.AttributeDroppedAttributesCount, uint64(2))
+	events := spanEventRows(t,
+		spanEvent{"first", time.Millisecond, map[string]any{"k": "v"}},
+		spanEvent{"second", 2 * time.Millisecond, map[string]any{"k": "v", "l": "w"}},
+		spanEvent{"third", 3 * time.Millisecond, map[string]any{"k": "value"}},
+	)
+	td := writeTraces(t, ot, append([]telegraf.Metric{span}, events...)...)
+	require.Equal(t, 1, td.SpanCount())
+	s := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0)
+	assert.Equal(t, map[string]any{
+		"a": "héll",
+		"b": []any{"abcd", "ab"},
+		"c": int64(12345),
+	}, s.Attributes().AsRaw())
+	assert.Equal(t, uint32(4), s.DroppedAttributesCount())
+	// The resource is built from the span attributes, so it's limited too
+	resource := td.ResourceSpans().At(0).Resource()
+	assert.Equal(t, map[string]any{
+		"a": "héll",
+		"b": []any{"abcd", "ab"},
+		"c": int64(12345),
+	}, resource.Attributes().AsRaw())
+	assert.Equal(t, uint32(2), resource.DroppedAttributesCount())
+
+	require.Equal(t, 2, s.Events().Len())
+	assert.Equal(t, uint32(1), s.DroppedEventsCount())
+	second := s.Events().At(0)
+	assert.Equal(t, "second", second.Name())
+	assert.Equal(t, map[string]any{"k": "v"}, second.Attributes().AsRaw())
+	assert.Equal(t, uint32(1), second.DroppedAttributesCount())
+	third := s.Events().At(1)
+	assert.Equal(t, "third", third.Name())
+	assert.Equal(t, map[string]any{"k": "valu"}, third.Attributes().AsRaw())
+	assert.Equal(t, uint32(0), third.DroppedAttributesCount())
+}
+
+func TestOtelTraceLimitsInit(t *testing.T) {
+	ot := &oteltrace.OtelTrace{Limits: &oteltrace.SpanLimits{LinkCount: -1}}
+	assert.ErrorContains(t, ot.Init(), "span limit link_count can't be negative")
+}
@@ -0,0 +1,211 @@
+package oteltrace
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	influxcommon "github.com/influxdata/influxdb-observability/common"
+	"github.com/influxdata/telegraf"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/pdatautil"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	semconv "go.opentelemetry.io/collector/semconv/v1.16.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+// logsExporter is implemented by the exporters that can also send OTLP logs.
+type logsExporter interface {
+	ExportLogs(ctx context.Context, ld plog.Logs) error
+}
+
+func (e *otlpGRPCExporter) ExportLogs(ctx context.Context, ld plog.Logs) error {
+	_, err := e.logsClient.Export(withHeaders(ctx, e.headers), plogotlp.NewExportRequestFromLogs(ld))
+	return err
+}
+
+func (e *otlpHTTPExporter) ExportLogs(ctx context.Context, ld plog.Logs) error {
+	body, err := plogotlp.NewExportRequestFromLogs(ld).MarshalProto()
+	if err != nil {
+		return fmt.Errorf("failed to marshal export request: %w", err)
+	}
+	return e.logsSender.send(ctx, body)
+}
+
+// logRecord is a converted log record along with the resource and scope it
+// was written with.
+type logRecord struct {
+	plog.LogRecord
+	resource pcommon.Map
+	scope    pcommon.InstrumentationScope
+	// eventName is set when the record is a span event, which otel2influx
+	// writes to the logs measurement with its name instead of a body.
+	eventName string
+}
+
+// handleLogRecord reverses otel2influx's logs measurement. Resource, scope and
+// record attributes are all flattened into the same tags and attributes
+// field, so attributes in a semconv resource namespace are moved back to the
+// resource and the otel.library.* tags become the scope.
+func (o *OtelTrace) handleLogRecord(metric telegraf.Metric) (logRecord, error) {
+	o.Log.Debugf("handling log record: %s", metric.Name())
+	record := logRecord{
+		LogRecord: plog.NewLogRecord(),
+		resource:  pcommon.NewMap(),
+		scope:     pcommon.NewInstrumentationScope(),
+	}
+	record.SetTimestamp(pcommon.NewTimestampFromTime(metric.Time()))
+
+	for _, tag := range metric.TagList() {
+		switch tag.Key {
+		case o.Schema.TraceID:
+			traceID, err := trace.TraceIDFromHex(tag.Value)
+			if err != nil {
+				return record, fmt.Errorf("unable to convert trace ID hex string %s: %w", tag.Value, err)
+			}
+			record.SetTraceID(pcommon.TraceID(traceID))
+		case o.Schema.SpanID:
+			spanID, err := trace.SpanIDFromHex(tag.Value)
+			if err != nil {
+				return record, fmt.Errorf("unable to convert span ID hex string %s: %w", tag.Value, err)
+			}
+			record.SetSpanID(pcommon.SpanID(spanID))
+		case semconv.OtelLibraryName:
+			record.scope.SetName(tag.Value)
+		case semconv.OtelLibraryVersion:
+			record.scope.SetVersion(tag.Value)
+		default:
+			o.decoder.putTag(record.attributesFor(tag.Key), tag.Key, tag.Value)
+		}
+	}
+
+	for _, field := range metric.FieldList() {
+		switch field.Key {
+		case influxcommon.AttributeBody:
+			if err := record.Body().FromRaw(field.Value); err != nil {
+				return record, fmt.Errorf("invalid type for log body %v: %w", field.Value, err)
+			}
+		case semconv.AttributeEventName:
+			eventName, ok := field.Value.(string)
+			if !ok {
+				return record, fmt.Errorf("invalid type for event name %v", field.Value)
+			}
+			// Exported log records carry it as the semconv event.name
+			// attribute
+			record.eventName = eventName
+			record.Attributes().PutStr(semconv.AttributeEventName, eventName)
+		case influxcommon.AttributeSeverityNumber:
+			severityNumber, ok := field.Value.(int64)
+			if !ok {
+				return record, fmt.Errorf("invalid type for severity number %v", field.Value)
+			}
+			record.SetSeverityNumber(plog.SeverityNumber(severityNumber))
+		case influxcommon.AttributeSeverityText:
+			severityText, ok := field.Value.(string)
+			if !ok {
+				return record, fmt.Errorf("invalid type for severity text %v", field.Value)
+			}
+			record.SetSeverityText(severityText)
+		case influxcommon.AttributeObservedTimeUnixNano:
+			observedTime, ok := field.Value.(int64)
+			if !ok {
+				return record, fmt.Errorf("invalid type for observed_time_unix_nano %v", field.Value)
+			}
+			record.SetObservedTimestamp(pcommon.NewTimestampFromTime(time.Unix(0, observedTime)))
+		case influxcommon.AttributeFlags:
+			flags, ok := uint32Field(field.Value)
+			if !ok {
+				return record, fmt.Errorf("invalid type for flags %v", field.Value)
+			}
+			record.SetFlags(plog.LogRecordFlags(flags))
+		case influxcommon.AttributeDroppedAttributesCount:
+			droppedAttrCount, ok := uint32Field(field.Value)
+			if !ok {
+				return record, fmt.Errorf("invalid type for dropped attributes count %v", field.Value)
+			}
+			record.SetDroppedAttributesCount(droppedAttrCount)
+		case influxcommon.AttributeAttributes:
+			attributesRawStr, ok := field.Value.(string)
+			if !ok {
+				return record, fmt.Errorf("invalid type for attributes %v", field.Value)
+			}
+			attributesField, err := o.decoder.decode(attributesRawStr)
+			if err != nil {
+				return record, fmt.Errorf("failed to unmarshal attributes to map %w", err)
+			}
+			for k, v := range attributesField {
+				o.decoder.put(record.attributesFor(k), k, v)
+			}
+		}
+	}
+	return record, nil
+}
+
+// attributesFor returns the resource's attributes for keys in a semconv
+// resource namespace, the record's otherwise.
+func (r logRecord) attributesFor(k string) pcommon.Map {
+	if influxcommon.ResourceNamespace.MatchString(k) {
+		return r.resource
+	}
+	return r.Attributes()
+}
+
+// logsBatch groups log records by resource and scope.
+type logsBatch struct {
+	logs      plog.Logs
+	resources map[[16]byte]plog.ResourceLogs
+	scopes    map[[16]byte]map[string]plog.ScopeLogs
+}
+
+func newLogsBatch() *logsBatch {
+	return &logsBatch{
+		logs:      plog.NewLogs(),
+		resources: map[[16]byte]plog.ResourceLogs{},
+		scopes:    map[[16]byte]map[string]plog.ScopeLogs{},
+	}
+}
+
+func (b *logsBatch) add(record logRecord) {
+	resourceKey := pdatautil.MapHash(record.resource)
+	resourceLogs, ok := b.resources[resourceKey]
+	if !ok {
+		resourceLogs = b.logs.ResourceLogs().AppendEmpty()
+		record.resource.CopyTo(resourceLogs.Resource().Attributes())
+		b.resources[resourceKey] = resourceLogs
+		b.scopes[resourceKey] = map[string]plog.ScopeLogs{}
+	}
+	scopeKey := record.scope.Name() + "\x00" + record.scope.Version()
+	scopeLogs, ok := b.scopes[resourceKey][scopeKey]
+	if !ok {
+		scopeLogs = resourceLogs.ScopeLogs().AppendEmpty()
+		record.scope.CopyTo(scopeLogs.Scope())
+		b.scopes[resourceKey][scopeKey] = scopeLogs
+	}
+	record.CopyTo(scopeLogs.LogRecords().AppendEmpty())
+}
+
+// exportLogs sends ld with the top level headers to the top level collector,
+// or to every named exporter that supports logs.
+func (o *OtelTrace) exportLogs(ctx context.Context, ld plog.Logs) error {
+	if len(o.Headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(o.Headers))
+	}
+	if len(o.exporters) == 0 {
+		_, err := o.logsClient.Export(ctx, plogotlp.NewExportRequestFromLogs(ld))
+		return err
+	}
+
+	exporters := exportersFor[logsExporter](o.exporters)
+	return o.replicate(ctx, exporters, "logs", func(ctx context.Context, e *namedExporter) error {
+		logs := ld
+		if len(exporters) > 1 {
+			logs = plog.NewLogs()
+			ld.CopyTo(logs)
+		}
+		return e.retry(ctx, func(ctx context.Context) error {
+			return e.exporter.(logsExporter).ExportLogs(ctx, logs)
+		})
+	})
+}
@@ -0,0 +1,175 @@
+package oteltrace_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/catherinetcai/telegraf-execd-otel/plugins/outputs/oteltrace"
+	influxcommon "github.com/influxdata/influxdb-observability/common"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/selfstat"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"google.golang.org/grpc"
+)
+
+// spanEvent is an event on the span from generateTraceAsMetric, offset from
+// the span's start.
+type spanEvent struct {
+	name       string
+	offset     time.Duration
+	attributes map[string]any
+}
+
+// spanEventRows returns the logs rows otel2influx writes for events on the
+// span from generateTraceAsMetric.
+func spanEventRows(t *testing.T, events ...spanEvent) []telegraf.Metric {
+	td := ptrace.NewTraces()
+	span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetTraceID(pcommon.TraceID{15: 1})
+	span.SetSpanID(pcommon.SpanID{7: 10})
+	start := time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
+	span.SetStartTimestamp(pcommon.NewTimestampFromTime(start))
+	for _, e := range events {
+		event := span.Events().AppendEmpty()
+		event.SetName(e.name)
+		event.SetTimestamp(pcommon.NewTimestampFromTime(start.Add(e.offset)))
+		require.NoError(t, event.Attributes().FromRaw(e.attributes))
+	}
+	var rows []telegraf.Metric
+	for _, m := range otel2influxMetrics(t, td) {
+		if m.Name() == influxcommon.MeasurementLogs {
+			rows = append(rows, m)
+		}
+	}
+	require.Len(t, rows, len(events))
+	return rows
+}
+
+// otelLogs returns a standalone log record as written by otel2influx, and
+// the row otel2influx writes for an event of the span from
+// generateTraceAsMetric.
+func otelLogs(t *testing.T) []telegraf.Metric {
+	ts := time.Date(2009, time.November, 10, 23, 0, 1, 0, time.UTC)
+	standalone := metric.New(influxcommon.MeasurementLogs,
+		map[string]string{
+			"service.name":      "checkout",
+			"otel.library.name": "logger",
+		},
+		map[string]interface{}{
+			influxcommon.AttributeBody:                 "cache warmed",
+			influxcommon.AttributeSeverityNumber:       int64(plog.SeverityNumberInfo),
+			influxcommon.AttributeSeverityText:         "INFO",
+			influxcommon.AttributeObservedTimeUnixNano: ts.Add(time.Second).UnixNano(),
+			influxcommon.AttributeAttributes:           `{"host.name":"web-1","entries":42}`,
+		},
+		ts,
+	)
+	correlated := spanEventRows(t, spanEvent{"payment declined", time.Second, map[string]any{"card.type": "visa"}})
+	return append([]telegraf.Metric{standalone}, correlated...)
+}
+
+func TestOtelTraceLogs(t *testing.T) {
+	tracesServer := &recordingTracesServer{}
+	logsServer := &recordingLogsServer{}
+	address := startServer(t, func(s *grpc.Server) {
+		ptraceotlp.RegisterGRPCServer(s, tracesServer)
+		plogotlp.RegisterGRPCServer(s, logsServer)
+	})
+	ot := &oteltrace.OtelTrace{
+		ServiceAddress: address,
+		Logs:           true,
+		LogSpanEvents:  true,
+		Log:            &testutil.Logger{},
+	}
+	require.NoError(t, ot.Init())
+	require.NoError(t, ot.Connect())
+	defer ot.Close()
+
+	// The correlated record comes before its span
+	require.NoError(t, ot.Write(append(otelLogs(t), generateTraceAsMetric())))
+
+	require.Len(t, logsServer.requests, 1)
+	rl := logsServer.requests[0].Logs().ResourceLogs()
+	require.Equal(t, 2, rl.Len(), "the span event has no resource attributes")
+	assert.Equal(t, map[string]any{"service.name": "checkout", "host.name": "web-1"}, rl.At(0).Resource().Attributes().AsRaw())
+	require.Equal(t, 1, rl.At(0).ScopeLogs().Len())
+	scopeLogs := rl.At(0).ScopeLogs().At(0)
+	assert.Equal(t, "logger", scopeLogs.Scope().Name())
+	require.Equal(t, 1, scopeLogs.LogRecords().Len())
+
+	standalone := scopeLogs.LogRecords().At(0)
+	assert.Equal(t, "cache warmed", standalone.Body().AsString())
+	assert.Equal(t, plog.SeverityNumberInfo, standalone.SeverityNumber())
+	assert.Equal(t, "INFO", standalone.SeverityText())
+	assert.Equal(t, standalone.Timestamp()+1e9, standalone.ObservedTimestamp())
+	assert.True(t, standalone.TraceID().IsEmpty())
+	assert.Equal(t, map[string]any{"entries": int64(42)}, standalone.Attributes().AsRaw())
+
+	assert.Empty(t, rl.At(1).Resource().Attributes().AsRaw())
+	require.Equal(t, 1, rl.At(1).ScopeLogs().Len())
+	require.Equal(t, 1, rl.At(1).ScopeLogs().At(0).LogRecords().Len())
+	correlated := rl.At(1).ScopeLogs().At(0).LogRecords().At(0)
+	assert.Equal(t, fmt.Sprintf("%032x", 1), correlated.TraceID().String())
+	assert.Equal(t, fmt.Sprintf("%016x", 10), correlated.SpanID().String())
+	assert.Empty(t, correlated.Body().AsString())
+	assert.Equal(t, map[string]any{"event.name": "payment declined", "card.type": "visa"}, correlated.Attributes().AsRaw())
+
+	require.Len(t, tracesServer.requests, 1)
+	span := tracesServer.requests[0].Traces().ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0)
+	require.Equal(t, 1, span.Events().Len())
+	event := span.Events().At(0)
+	assert.Equal(t, "payment declined", event.Name())
+	assert.Equal(t, correlated.Timestamp(), event.Timestamp())
+	assert.Equal(t, map[string]any{"card.type": "visa"}, event.Attributes().AsRaw())
+}
+
+func TestOtelTraceLogsDisabled(t *testing.T) {
+	tracesServer := &recordingTracesServer{}
+	logsServer := &recordingLogsServer{}
+	address := startServer(t, func(s *grpc.Server) {
+		ptraceotlp.RegisterGRPCServer(s, tracesServer)
+		plogotlp.RegisterGRPCServer(s, logsServer)
+	})
+	ot := &oteltrace.OtelTrace{
+		ServiceAddress: address,
+		Log:            &testutil.Logger{},
+	}
+	require.NoError(t, ot.Init())
+	require.NoError(t, ot.Connect())
+	defer ot.Close()
+
+	require.NoError(t, ot.Write(append(otelLogs(t), generateTraceAsMetric())))
+	assert.Empty(t, logsServer.requests)
+	require.Len(t, tracesServer.requests, 1)
+	span := tracesServer.requests[0].Traces().ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0)
+	assert.Equal(t, 0, span.Events().Len())
+}
+
+func TestOtelTraceLogsFailureKeepsTraces(t *testing.T) {
+	traces := &recordingTracesServer{}
+	// The collector doesn't accept logs
+	ot := &oteltrace.OtelTrace{
+		ServiceAddress: startTracesServer(t, traces),
+		Logs:           true,
+		Log:            &testutil.Logger{Quiet: true},
+	}
+	require.NoError(t, ot.Init())
+	require.NoError(t, ot.Connect())
+	defer ot.Close()
+	dropped := selfstat.Register("oteltrace", "dropped_log_records", nil)
+	before := dropped.Get()
+
+	// Failing the write would have Telegraf send the span again
+	require.NoError(t, ot.Write(append(otelLogs(t), generateTraceAsMetric())))
+	assert.Len(t, traces.requests, 1)
+	assert.Equal(t, int64(2), dropped.Get()-before)
+}
@@ -0,0 +1,102 @@
+package oteltrace
+
+import (
+	"context"
+	"fmt"
+
+	influxcommon "github.com/influxdata/influxdb-observability/common"
+	"github.com/influxdata/influxdb-observability/influx2otel"
+	"github.com/influxdata/telegraf"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"google.golang.org/grpc/metadata"
+)
+
+// metricsExporter is implemented by the exporters that can also send OTLP
+// metrics.
+type metricsExporter interface {
+	ExportMetrics(ctx context.Context, md pmetric.Metrics) error
+}
+
+func (e *otlpGRPCExporter) ExportMetrics(ctx context.Context, md pmetric.Metrics) error {
+	_, err := e.metricsClient.Export(withHeaders(ctx, e.headers), pmetricotlp.NewExportRequestFromMetrics(md))
+	return err
+}
+
+func (e *otlpHTTPExporter) ExportMetrics(ctx context.Context, md pmetric.Metrics) error {
+	body, err := pmetricotlp.NewExportRequestFromMetrics(md).MarshalProto()
+	if err != nil {
+		return fmt.Errorf("failed to marshal export request: %w", err)
+	}
+	return e.metricsSender.send(ctx, body)
+}
+
+// influxLogger adapts Telegraf's logger to the one influx2otel expects.
+type influxLogger struct {
+	telegraf.Logger
+}
+
+func (l influxLogger) Debug(msg string, kv ...interface{}) {
+	l.Logger.Debug(append([]interface{}{msg}, kv...)...)
+}
+
+// influxValueType maps Telegraf's metric types to the value types influx2otel
+// uses to pick the OTLP metric type. Untyped metrics are inferred from their
+// fields.
+func influxValueType(valueType telegraf.ValueType) influxcommon.InfluxMetricValueType {
+	switch valueType {
+	case telegraf.Gauge:
+		return influxcommon.InfluxMetricValueTypeGauge
+	case telegraf.Counter:
+		return influxcommon.InfluxMetricValueTypeSum
+	case telegraf.Histogram:
+		return influxcommon.InfluxMetricValueTypeHistogram
+	case telegraf.Summary:
+		return influxcommon.InfluxMetricValueTypeSummary
+	default:
+		return influxcommon.InfluxMetricValueTypeUntyped
+	}
+}
+
+// addMetric converts a metric written by otel2influx in either of Telegraf's
+// prometheus schemas back into OTLP. influx2otel tells the schemas apart by
+// the measurement name, prometheus-v2 writes everything to "prometheus".
+func addMetric(batch *influx2otel.MetricsBatch, metric telegraf.Metric) error {
+	return batch.AddPoint(metric.Name(), metric.Tags(), metric.Fields(), metric.Time(), influxValueType(metric.Type()))
+}
+
+// exportMetrics sends md with the top level headers to the top level
+// collector, or to every named exporter that supports metrics.
+func (o *OtelTrace) exportMetrics(ctx context.Context, md pmetric.Metrics) error {
+	if len(o.Headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(o.Headers))
+	}
+	if len(o.exporters) == 0 {
+		_, err := o.metricsClient.Export(ctx, pmetricotlp.NewExportRequestFromMetrics(md))
+		return err
+	}
+
+	exporters := exportersFor[metricsExporter](o.exporters)
+	return o.replicate(ctx, exporters, "metrics", func(ctx context.Context, e *namedExporter) error {
+		metrics := md
+		if len(exporters) > 1 {
+			metrics = pmetric.NewMetrics()
+			md.CopyTo(metrics)
+		}
+		return e.retry(ctx, func(ctx context.Context) error {
+			return e.exporter.(metricsExporter).ExportMetrics(ctx, metrics)
+		})
+	})
+}
+
+// exportersFor returns the exporters that can send the signal implemented by
+// T.
+func exportersFor[T any](exporters []*namedExporter) []*namedExporter {
+	var supported []*namedExporter
+	for _, e := range exporters {
+		if _, ok := e.exporter.(T); ok {
+			supported = append(supported, e)
+		}
+	}
+	return supported
+}
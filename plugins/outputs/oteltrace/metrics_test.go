@@ -0,0 +1,185 @@
+package oteltrace_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/catherinetcai/telegraf-execd-otel/plugins/outputs/oteltrace"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/selfstat"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"google.golang.org/grpc"
+)
+
+// otelMetrics returns metrics as written by otel2influx: a prometheus-v2
+// gauge, counter and histogram and a prometheus-v1 summary.
+func otelMetrics() []telegraf.Metric {
+	ts := time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
+	tags := map[string]string{
+		"service.name":      "checkout",
+		"otel.library.name": "meter",
+		"method":            "post",
+	}
+	withTags := func(extra map[string]string) map[string]string {
+		merged := map[string]string{}
+		for k, v := range tags {
+			merged[k] = v
+		}
+		for k, v := range extra {
+			merged[k] = v
+		}
+		return merged
+	}
+	return []telegraf.Metric{
+		metric.New("prometheus", tags, map[string]interface{}{"queue_length": float64(7)}, ts, telegraf.Gauge),
+		metric.New("prometheus", tags, map[string]interface{}{"http_requests_total": float64(1027)}, ts, telegraf.Counter),
+		metric.New("prometheus", tags, map[string]interface{}{
+			"http_request_duration_seconds_count": float64(10),
+			"http_request_duration_seconds_sum":   float64(2.5),
+		}, ts, telegraf.Histogram),
+		metric.New("prometheus", withTags(map[string]string{"le": "0.1"}), map[string]interface{}{
+			"http_request_duration_seconds_bucket": float64(4),
+		}, ts, telegraf.Histogram),
+		metric.New("prometheus", withTags(map[string]string{"le": "+Inf"}), map[string]interface{}{
+			"http_request_duration_seconds_bucket": float64(10),
+		}, ts, telegraf.Histogram),
+		metric.New("rpc_duration_seconds", tags, map[string]interface{}{
+			"count": float64(5),
+			"sum":   float64(1.5),
+			"0.5":   float64(0.2),
+			"0.99":  float64(0.9),
+		}, ts, telegraf.Summary),
+	}
+}
+
+func TestOtelTraceMetrics(t *testing.T) {
+	metricsServer := &recordingMetricsServer{}
+	address := startServer(t, func(s *grpc.Server) {
+		ptraceotlp.RegisterGRPCServer(s, &recordingTracesServer{})
+		pmetricotlp.RegisterGRPCServer(s, metricsServer)
+	})
+	ot := &oteltrace.OtelTrace{
+		ServiceAddress: address,
+		Headers:        map[string]string{"X-Scope-OrgID": "default"},
+		Metrics:        true,
+		Log:            &testutil.Logger{},
+	}
+	require.NoError(t, ot.Init())
+	require.NoError(t, ot.Connect())
+	defer ot.Close()
+
+	require.NoError(t, ot.Write(append(otelMetrics(), generateTraceAsMetric())))
+
+	require.Len(t, metricsServer.requests, 1)
+	assert.Equal(t, []string{"default"}, metricsServer.metadata[0].Get("X-Scope-OrgID"))
+	rm := metricsServer.requests[0].Metrics().ResourceMetrics()
+	require.Equal(t, 1, rm.Len())
+	serviceName, ok := rm.At(0).Resource().Attributes().Get("service.name")
+	require.True(t, ok)
+	assert.Equal(t, "checkout", serviceName.AsString())
+	require.Equal(t, 1, rm.At(0).ScopeMetrics().Len())
+	sm := rm.At(0).ScopeMetrics().At(0)
+	assert.Equal(t, "meter", sm.Scope().Name())
+
+	byName := map[string]pmetric.Metric{}
+	for i := 0; i < sm.Metrics().Len(); i++ {
+		byName[sm.Metrics().At(i).Name()] = sm.Metrics().At(i)
+	}
+	require.Len(t, byName, 4)
+
+	gauge := byName["queue_length"]
+	require.Equal(t, pmetric.MetricTypeGauge, gauge.Type())
+	assert.Equal(t, float64(7), gauge.Gauge().DataPoints().At(0).DoubleValue())
+
+	sum := byName["http_requests_total"]
+	require.Equal(t, pmetric.MetricTypeSum, sum.Type())
+	assert.True(t, sum.Sum().IsMonotonic())
+	assert.Equal(t, pmetric.AggregationTemporalityCumulative, sum.Sum().AggregationTemporality())
+	assert.Equal(t, float64(1027), sum.Sum().DataPoints().At(0).DoubleValue())
+
+	histogram := byName["http_request_duration_seconds"]
+	require.Equal(t, pmetric.MetricTypeHistogram, histogram.Type())
+	hdp := histogram.Histogram().DataPoints().At(0)
+	assert.Equal(t, uint64(10), hdp.Count())
+	assert.Equal(t, 2.5, hdp.Sum())
+	assert.Equal(t, []float64{0.1}, hdp.ExplicitBounds().AsRaw())
+	assert.Equal(t, []uint64{4, 6}, hdp.BucketCounts().AsRaw())
+
+	summary := byName["rpc_duration_seconds"]
+	require.Equal(t, pmetric.MetricTypeSummary, summary.Type())
+	sdp := summary.Summary().DataPoints().At(0)
+	assert.Equal(t, uint64(5), sdp.Count())
+	assert.Equal(t, 2, sdp.QuantileValues().Len())
+}
+
+func TestOtelTraceMetricsDisabled(t *testing.T) {
+	metricsServer := &recordingMetricsServer{}
+	address := startServer(t, func(s *grpc.Server) {
+		ptraceotlp.RegisterGRPCServer(s, &recordingTracesServer{})
+		pmetricotlp.RegisterGRPCServer(s, metricsServer)
+	})
+	ot := &oteltrace.OtelTrace{
+		ServiceAddress: address,
+		Log:            &testutil.Logger{},
+	}
+	require.NoError(t, ot.Init())
+	require.NoError(t, ot.Connect())
+	defer ot.Close()
+
+	require.NoError(t, ot.Write(otelMetrics()))
+	assert.Empty(t, metricsServer.requests)
+}
+
+func TestOtelTraceMetricsExporters(t *testing.T) {
+	metricsServer := &recordingMetricsServer{}
+	address := startServer(t, func(s *grpc.Server) {
+		pmetricotlp.RegisterGRPCServer(s, metricsServer)
+	})
+	ot := &oteltrace.OtelTrace{
+		Metrics: true,
+		Exporters: []*oteltrace.ExporterConfig{
+			{Name: "otlp", ServiceAddress: address},
+			{Name: "debug", Type: "debug"},
+		},
+		Log: &testutil.Logger{},
+	}
+	require.NoError(t, ot.Init())
+	require.NoError(t, ot.Connect())
+	defer ot.Close()
+
+	require.NoError(t, ot.Write(otelMetrics()))
+	require.Len(t, metricsServer.requests, 1)
+	assert.Equal(t, 4, metricsServer.requests[0].Metrics().MetricCount())
+
+	ot = &oteltrace.OtelTrace{
+		Metrics:   true,
+		Exporters: []*oteltrace.ExporterConfig{{Name: "debug", Type: "debug"}},
+	}
+	assert.ErrorContains(t, ot.Init(), "metrics need at least one otlp exporter")
+}
+
+func TestOtelTraceMetricsFailureKeepsTraces(t *testing.T) {
+	traces := &recordingTracesServer{}
+	// The collector doesn't accept metrics
+	ot := &oteltrace.OtelTrace{
+		ServiceAddress: startTracesServer(t, traces),
+		Metrics:        true,
+		Log:            &testutil.Logger{Quiet: true},
+	}
+	require.NoError(t, ot.Init())
+	require.NoError(t, ot.Connect())
+	defer ot.Close()
+	dropped := selfstat.Register("oteltrace", "dropped_metric_data_points", nil)
+	before := dropped.Get()
+
+	// Failing the write would have Telegraf send the spans again
+	require.NoError(t, ot.Write(append(otelMetrics(), generateTraceAsMetric())))
+	assert.Len(t, traces.requests, 1)
+	assert.Positive(t, dropped.Get()-before)
+}
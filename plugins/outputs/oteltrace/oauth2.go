@@ -0,0 +1,144 @@
+package oteltrace
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	commontls "github.com/influxdata/telegraf/plugins/common/tls"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+var _ credentials.PerRPCCredentials = (*oauth2TokenSource)(nil)
+
+// Tokens are refreshed this long before they actually expire so that an
+// export in flight doesn't race the expiry.
+const oauth2RefreshBefore = 30 * time.Second
+
+// OAuth2Config configures the client-credentials flow used to authenticate
+// exports.
+type OAuth2Config struct {
+	TokenURL         string   `toml:"token_url"`
+	ClientID         string   `toml:"client_id"`
+	ClientSecret     string   `toml:"client_secret"`
+	ClientSecretFile string   `toml:"client_secret_file"`
+	Scopes           []string `toml:"scopes"`
+	Audience         string   `toml:"audience"`
+}
+
+// tokenSource returns the token source for the config. Tokens are only sent
+// over TLS unless insecure is set, for connections with TLS explicitly
+// disabled.
+func (c *OAuth2Config) tokenSource(insecure bool) (*oauth2TokenSource, error) {
+	if c.TokenURL == "" {
+		return nil, fmt.Errorf("oauth2 token_url is required")
+	}
+	if c.ClientID == "" {
+		return nil, fmt.Errorf("oauth2 client_id is required")
+	}
+	if c.ClientSecret != "" && c.ClientSecretFile != "" {
+		return nil, fmt.Errorf("oauth2 client_secret and client_secret_file are mutually exclusive")
+	}
+
+	clientSecret := c.ClientSecret
+	if c.ClientSecretFile != "" {
+		secret, err := os.ReadFile(c.ClientSecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read oauth2 client_secret_file %s: %w", c.ClientSecretFile, err)
+		}
+		clientSecret = strings.TrimSpace(string(secret))
+	}
+	if clientSecret == "" {
+		return nil, fmt.Errorf("oauth2 client_secret or client_secret_file is required")
+	}
+
+	conf := &clientcredentials.Config{
+		ClientID:     c.ClientID,
+		ClientSecret: clientSecret,
+		TokenURL:     c.TokenURL,
+		Scopes:       c.Scopes,
+	}
+	if c.Audience != "" {
+		conf.EndpointParams = url.Values{"audience": []string{c.Audience}}
+	}
+	return &oauth2TokenSource{conf: conf, insecure: insecure}, nil
+}
+
+// oauth2TokenSource caches client-credentials tokens and hands them to gRPC as
+// per-RPC credentials.
+type oauth2TokenSource struct {
+	conf     *clientcredentials.Config
+	insecure bool
+
+	mu    sync.Mutex
+	token *oauth2.Token
+}
+
+func (s *oauth2TokenSource) Token(ctx context.Context) (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != nil && (s.token.Expiry.IsZero() || time.Until(s.token.Expiry) > oauth2RefreshBefore) {
+		return s.token, nil
+	}
+	token, err := s.conf.Token(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch oauth2 token from %s: %w", s.conf.TokenURL, err)
+	}
+	s.token = token
+	return token, nil
+}
+
+// invalidate drops the cached token so the next call fetches a new one.
+func (s *oauth2TokenSource) invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = nil
+}
+
+func (s *oauth2TokenSource) GetRequestMetadata(ctx context.Context, _ ...string) (map[string]string, error) {
+	token, err := s.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"authorization": token.Type() + " " + token.AccessToken}, nil
+}
+
+// Tokens are credentials, so they're only sent in the clear when TLS was
+// disabled with tls_enable = false.
+func (s *oauth2TokenSource) RequireTransportSecurity() bool {
+	return !s.insecure
+}
+
+// tlsDisabled reports whether TLS was explicitly disabled with
+// tls_enable = false.
+func tlsDisabled(c commontls.ClientConfig) bool {
+	return c.Enable != nil && !*c.Enable
+}
+
+// retryUnauthenticated retries a call once with a fresh token when the server
+// rejects the cached one.
+func (s *oauth2TokenSource) retryUnauthenticated(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if status.Code(err) != codes.Unauthenticated {
+		return err
+	}
+	s.invalidate()
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+func (s *oauth2TokenSource) dialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithPerRPCCredentials(s),
+		grpc.WithUnaryInterceptor(s.retryUnauthenticated),
+	}
+}
@@ -0,0 +1,123 @@
+package oteltrace_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/catherinetcai/telegraf-execd-otel/plugins/outputs/oteltrace"
+	"github.com/influxdata/telegraf"
+	commontls "github.com/influxdata/telegraf/plugins/common/tls"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTokenServer(t *testing.T, issued *atomic.Int32) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "client_credentials", r.Form.Get("grant_type"))
+		assert.Equal(t, "https://traces.example.com", r.Form.Get("audience"))
+		n := issued.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		assert.NoError(t, json.NewEncoder(w).Encode(map[string]any{
+			"access_token": fmt.Sprintf("token-%d", n),
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		}))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestOtelTraceOAuth2(t *testing.T) {
+	var issued atomic.Int32
+	tokenServer := newTokenServer(t, &issued)
+	traces := &recordingTracesServer{}
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(secretFile, []byte("s3cr3t\n"), 0o600))
+
+	tlsEnable := false
+	ot := &oteltrace.OtelTrace{
+		ServiceAddress: startTracesServer(t, traces),
+		// The test collector doesn't speak TLS
+		ClientConfig: commontls.ClientConfig{Enable: &tlsEnable},
+		OAuth2: &oteltrace.OAuth2Config{
+			TokenURL:         tokenServer.URL,
+			ClientID:         "telegraf",
+			ClientSecretFile: secretFile,
+			Audience:         "https://traces.example.com",
+		},
+		Log: &testutil.Logger{},
+	}
+	require.NoError(t, ot.Init())
+	require.NoError(t, ot.Connect())
+	defer ot.Close()
+
+	require.NoError(t, ot.Write([]telegraf.Metric{generateTraceAsMetric()}))
+	require.NoError(t, ot.Write([]telegraf.Metric{generateTraceAsMetric()}))
+	// The token is cached between exports
+	assert.Equal(t, int32(1), issued.Load())
+	require.Len(t, traces.metadata, 2)
+	assert.Equal(t, []string{"Bearer token-1"}, traces.metadata[1].Get("authorization"))
+
+	// A rejected token is refreshed and the export retried once
+	traces.rejectFirst = 1
+	require.NoError(t, ot.Write([]telegraf.Metric{generateTraceAsMetric()}))
+	assert.Equal(t, int32(2), issued.Load())
+	require.Len(t, traces.metadata, 4)
+	assert.Equal(t, []string{"Bearer token-2"}, traces.metadata[3].Get("authorization"))
+	assert.Len(t, traces.requests, 3)
+}
+
+func TestOtelTraceOAuth2RequiresTLS(t *testing.T) {
+	var issued atomic.Int32
+	tokenServer := newTokenServer(t, &issued)
+	oauth2 := &oteltrace.OAuth2Config{
+		TokenURL:     tokenServer.URL,
+		ClientID:     "telegraf",
+		ClientSecret: "s3cr3t",
+		Audience:     "https://traces.example.com",
+	}
+
+	ot := &oteltrace.OtelTrace{
+		ServiceAddress: "127.0.0.1:4317",
+		OAuth2:         oauth2,
+		Log:            &testutil.Logger{Quiet: true},
+	}
+	require.NoError(t, ot.Init())
+	assert.ErrorContains(t, ot.Connect(), "transport level security")
+
+	var posts atomic.Int32
+	collector := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		posts.Add(1)
+	}))
+	t.Cleanup(collector.Close)
+	ot = &oteltrace.OtelTrace{
+		Exporters: []*oteltrace.ExporterConfig{{
+			Name:           "http",
+			Protocol:       "http",
+			ServiceAddress: collector.URL,
+			OAuth2:         oauth2,
+		}},
+		Log: &testutil.Logger{Quiet: true},
+	}
+	require.NoError(t, ot.Init())
+	require.NoError(t, ot.Connect())
+	defer ot.Close()
+	assert.ErrorContains(t, ot.Write([]telegraf.Metric{generateTraceAsMetric()}), "oauth2 tokens are only sent over TLS")
+	assert.Equal(t, int32(0), issued.Load())
+	assert.Equal(t, int32(0), posts.Load())
+}
+
+func TestOtelTraceOAuth2Init(t *testing.T) {
+	ot := &oteltrace.OtelTrace{
+		OAuth2: &oteltrace.OAuth2Config{TokenURL: "http://localhost/token", ClientID: "telegraf"},
+	}
+	assert.ErrorContains(t, ot.Init(), "client_secret")
+}
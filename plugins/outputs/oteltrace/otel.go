@@ -2,16 +2,27 @@ package oteltrace
 
 import (
 	"context"
+	"crypto/tls"
 	_ "embed"
 	"fmt"
+	"os"
+	"slices"
+	"time"
 
-	influxcommon "github.com/influxdata/influxdb-observability/common"
+	"github.com/influxdata/influxdb-observability/influx2otel"
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	commontls "github.com/influxdata/telegraf/plugins/common/tls"
 	"github.com/influxdata/telegraf/plugins/outputs"
+	"github.com/influxdata/telegraf/selfstat"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
@@ -28,12 +39,54 @@ const (
 	defaultServiceAddress = "localhost:4317"
 )
 
+// Telegraf adds the host tag to every metric unless omit_hostname is set, so
+// it isn't taken for a span attribute. A configured exclusion list replaces it.
+var defaultUnknownColumnsExclude = []string{"host"}
+
 type OtelTrace struct {
-	Debug          bool   `toml:"debug"`
-	ServiceAddress string `toml:"service_address"`
-	Exporter       ptraceotlp.GRPCClient
+	Debug                 bool                   `toml:"debug"`
+	DebugVerbosity        string                 `toml:"debug_verbosity"`
+	ServiceAddress        string                 `toml:"service_address"`
+	Headers               map[string]string      `toml:"headers"`
+	OAuth2                *OAuth2Config          `toml:"oauth2"`
+	Routes                []*Route               `toml:"route"`
+	Exporters             []*ExporterConfig      `toml:"exporter"`
+	FanOutTimeout         config.Duration        `toml:"fan_out_timeout"`
+	Metrics               bool                   `toml:"metrics"`
+	Logs                  bool                   `toml:"logs"`
+	LogSpanEvents         bool                   `toml:"log_span_events"`
+	SpanMappings          []*SpanMapping         `toml:"span_mapping"`
+	SpanMetrics           *SpanMetricsConfig     `toml:"span_metrics"`
+	ServiceGraph          *ServiceGraphConfig    `toml:"service_graph"`
+	Attributes            []*AttributesProcessor `toml:"attributes"`
+	Redaction             *RedactionConfig       `toml:"redaction"`
+	Filters               []*SpanFilter          `toml:"filter"`
+	Transforms            []*TransformProcessor  `toml:"transform"`
+	Semconv               *SemconvMigration      `toml:"semconv_migration"`
+	SpanNames             *SpanNameNormalization `toml:"span_name_normalization"`
+	Resource              *ResourceDetection     `toml:"resource_detection"`
+	Limits                *SpanLimits            `toml:"limits"`
+	BytesAttributes       []string               `toml:"bytes_attributes"`
+	IgnoreUnknownColumns  bool                   `toml:"ignore_unknown_columns"`
+	UnknownColumnsExclude []string               `toml:"unknown_columns_exclude"`
+	Schema                Schema                 `toml:"schema"`
+	Exporter              ptraceotlp.GRPCClient
+	commontls.ClientConfig
 
-	clientConn *grpc.ClientConn
+	clientConn       *grpc.ClientConn
+	metricsClient    pmetricotlp.GRPCClient
+	logsClient       plogotlp.GRPCClient
+	metricsConverter *influx2otel.LineProtocolToOtelMetrics
+	droppedMetrics   selfstat.Stat
+	droppedLogs      selfstat.Stat
+	spanMappings     map[string]*SpanMapping
+	decoder          attributeDecoder
+	generators       []*generator
+	processors       []spanProcessor
+	debugExporter    *debugExporter
+	tlsConfig        *tls.Config
+	tokenSource      *oauth2TokenSource
+	exporters        []*namedExporter
 
 	Log telegraf.Logger `toml:"-"`
 }
@@ -42,6 +95,168 @@ func (o *OtelTrace) Init() error {
 	if o.ServiceAddress == "" {
 		o.ServiceAddress = defaultServiceAddress
 	}
+	if err := o.Schema.init(); err != nil {
+		return err
+	}
+	o.decoder = newAttributeDecoder(o.BytesAttributes)
+	if o.UnknownColumnsExclude == nil {
+		o.UnknownColumnsExclude = defaultUnknownColumnsExclude
+	}
+	tlsConfig, err := o.ClientConfig.TLSConfig()
+	if err != nil {
+		return err
+	}
+	o.tlsConfig = tlsConfig
+	if o.OAuth2 != nil {
+		tokenSource, err := o.OAuth2.tokenSource(tlsDisabled(o.ClientConfig))
+		if err != nil {
+			return err
+		}
+		o.tokenSource = tokenSource
+	}
+	if o.Debug {
+		debugConfig := DebugExporterConfig{Verbosity: o.DebugVerbosity}
+		if err := debugConfig.init(); err != nil {
+			return err
+		}
+		o.debugExporter = newDebugExporter(os.Stderr, debugConfig)
+	}
+	exporterNames := map[string]bool{}
+	for _, exporter := range o.Exporters {
+		if err := exporter.init(); err != nil {
+			return err
+		}
+		if exporterNames[exporter.Name] {
+			return fmt.Errorf("duplicate exporter name %s", exporter.Name)
+		}
+		exporterNames[exporter.Name] = true
+	}
+	hasOTLPExporter := len(o.Exporters) == 0 || slices.ContainsFunc(o.Exporters, func(c *ExporterConfig) bool { return c.Type == exporterOTLP })
+	if o.Logs {
+		if !hasOTLPExporter {
+			return fmt.Errorf("logs need at least one otlp exporter")
+		}
+		o.droppedLogs = selfstat.Register("oteltrace", "dropped_log_records", nil)
+	}
+	if o.SpanMetrics != nil {
+		if err := o.SpanMetrics.init(); err != nil {
+			return err
+		}
+		if o.SpanMetrics.ServiceAddress == "" && !hasOTLPExporter {
+			return fmt.Errorf("span metrics need a service_address or at least one otlp exporter")
+		}
+		o.generators = append(o.generators, &generator{
+			name:             "span metrics",
+			interval:         time.Duration(o.SpanMetrics.Interval),
+			serviceAddress:   o.SpanMetrics.ServiceAddress,
+			metricsGenerator: newSpanMetrics(o.SpanMetrics),
+		})
+	}
+	if o.ServiceGraph != nil {
+		if err := o.ServiceGraph.init(); err != nil {
+			return err
+		}
+		if o.ServiceGraph.ServiceAddress == "" && !hasOTLPExporter {
+			return fmt.Errorf("service graph needs a service_address or at least one otlp exporter")
+		}
+		o.generators = append(o.generators, &generator{
+			name:             "service graph",
+			interval:         time.Duration(o.ServiceGraph.Interval),
+			serviceAddress:   o.ServiceGraph.ServiceAddress,
+			metricsGenerator: newServiceGraph(o.ServiceGraph),
+		})
+	}
+	if o.Metrics {
+		if !hasOTLPExporter {
+			return fmt.Errorf("metrics need at least one otlp exporter")
+		}
+		converter, err := influx2otel.NewLineProtocolToOtelMetrics(influxLogger{o.Log})
+		if err != nil {
+			return err
+		}
+		o.metricsConverter = converter
+		o.droppedMetrics = selfstat.Register("oteltrace", "dropped_metric_data_points", nil)
+	}
+	o.spanMappings = make(map[string]*SpanMapping, len(o.SpanMappings))
+	for _, mapping := range o.SpanMappings {
+		if err := mapping.init(); err != nil {
+			return err
+		}
+		if o.Schema.measurement(mapping.Measurement) {
+			return fmt.Errorf("span mapping %s: measurement is already converted", mapping.Measurement)
+		}
+		if _, ok := o.spanMappings[mapping.Measurement]; ok {
+			return fmt.Errorf("duplicate span mapping for measurement %s", mapping.Measurement)
+		}
+		o.spanMappings[mapping.Measurement] = mapping
+	}
+	if o.Limits != nil {
+		if err := o.Limits.init(); err != nil {
+			return err
+		}
+	}
+	filterNames := map[string]bool{}
+	for _, f := range o.Filters {
+		if err := f.init(); err != nil {
+			return err
+		}
+		if filterNames[f.Name] {
+			return fmt.Errorf("duplicate filter name %s", f.Name)
+		}
+		filterNames[f.Name] = true
+	}
+	// Detected attributes are merged first so that the processors see them
+	// like the ones sent by the services
+	if o.Resource != nil {
+		if err := o.Resource.init(o.Log); err != nil {
+			return err
+		}
+		o.processors = append(o.processors, o.Resource)
+	}
+	// Keys are then migrated so that the other processors only need to
+	// know about the target version's keys
+	if o.Semconv != nil {
+		if err := o.Semconv.init(o.Log); err != nil {
+			return err
+		}
+		o.processors = append(o.processors, o.Semconv)
+	}
+	for _, p := range o.Attributes {
+		if err := p.init(); err != nil {
+			return err
+		}
+		o.processors = append(o.processors, p)
+	}
+	for _, p := range o.Transforms {
+		if err := p.init(o.Log); err != nil {
+			return err
+		}
+		o.processors = append(o.processors, p)
+	}
+	if o.SpanNames != nil {
+		if err := o.SpanNames.init(); err != nil {
+			return err
+		}
+		o.processors = append(o.processors, o.SpanNames)
+	}
+	// Redaction comes last so that nothing added before it is exported
+	// unscrubbed
+	if o.Redaction != nil {
+		if err := o.Redaction.init(); err != nil {
+			return err
+		}
+		o.processors = append(o.processors, o.Redaction)
+	}
+	for _, route := range o.Routes {
+		if err := route.init(o.Headers); err != nil {
+			return err
+		}
+		for _, name := range route.Exporters {
+			if !exporterNames[name] {
+				return fmt.Errorf("route %s: unknown exporter %s", route.Name, name)
+			}
+		}
+	}
 
 	return nil
 }
@@ -51,24 +266,98 @@ func (o *OtelTrace) SampleConfig() string {
 }
 
 func (o *OtelTrace) Connect() error {
-	var err error
-	o.Log.Debugf("connecting to trace exporter at: %s", o.ServiceAddress)
-	conn, err := grpc.NewClient(
-		o.ServiceAddress,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-	)
+	// Named exporters replace the top level service_address
+	if len(o.Exporters) == 0 {
+		conn, err := o.dial(o.ServiceAddress, o.tlsConfig, o.tokenSource)
+		if err != nil {
+			return err
+		}
+		traceExporter := ptraceotlp.NewGRPCClient(conn)
+		o.clientConn = conn
+		o.Exporter = traceExporter
+		o.metricsClient = pmetricotlp.NewGRPCClient(conn)
+		o.logsClient = plogotlp.NewGRPCClient(conn)
+	}
+
+	byName := map[string]*namedExporter{}
+	for _, c := range o.Exporters {
+		exporter, err := o.newExporter(c)
+		if err != nil {
+			return err
+		}
+		o.exporters = append(o.exporters, exporter)
+		byName[c.Name] = exporter
+	}
+
+	for _, route := range o.Routes {
+		for _, name := range route.Exporters {
+			route.exporters = append(route.exporters, byName[name])
+		}
+		if route.ServiceAddress == "" {
+			continue
+		}
+		exporter, err := o.newExporter(&ExporterConfig{
+			Name:           route.Name,
+			Protocol:       protocolGRPC,
+			ServiceAddress: route.ServiceAddress,
+			Timeout:        defaultExporterTimeout,
+			tlsConfig:      o.tlsConfig,
+			tokenSource:    o.tokenSource,
+		})
+		if err != nil {
+			return err
+		}
+		route.exporters = []*namedExporter{exporter}
+	}
+	for _, g := range o.generators {
+		if err := o.startGenerator(g); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *OtelTrace) dial(address string, tlsConfig *tls.Config, tokenSource *oauth2TokenSource, extraOpts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	o.Log.Debugf("connecting to trace exporter at: %s", address)
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	if tlsConfig != nil {
+		opts = []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))}
+	}
+	opts = append(opts, extraOpts...)
+	if tokenSource != nil {
+		opts = append(opts, tokenSource.dialOptions()...)
+	}
+	conn, err := grpc.NewClient(address, opts...)
 	if err != nil {
-		wrappedErr := fmt.Errorf("failed to create grpc client for %s - err: %w", o.ServiceAddress, err)
+		wrappedErr := fmt.Errorf("failed to create grpc client for %s - err: %w", address, err)
 		o.Log.Error(wrappedErr)
-		return err
+		return nil, err
 	}
-	traceExporter := ptraceotlp.NewGRPCClient(conn)
-	o.clientConn = conn
-	o.Exporter = traceExporter
-	return nil
+	return conn, nil
 }
 
 func (o *OtelTrace) Close() error {
+	// Generated metrics are sent before the connections they might use are
+	// closed
+	for _, g := range o.generators {
+		if err := o.stopGenerator(g); err != nil {
+			return err
+		}
+	}
+	for _, route := range o.Routes {
+		if route.ServiceAddress != "" && len(route.exporters) > 0 {
+			o.Log.Debugf("closing Otel client connection for route %s", route.Name)
+			if err := route.exporters[0].exporter.Close(); err != nil {
+				return err
+			}
+		}
+	}
+	for _, exporter := range o.exporters {
+		o.Log.Debugf("closing exporter %s", exporter.name)
+		if err := exporter.exporter.Close(); err != nil {
+			return err
+		}
+	}
 	if o.clientConn != nil {
 		o.Log.Debug("closing Otel client connection")
 		return o.clientConn.Close()
@@ -88,93 +377,228 @@ func (o *OtelTrace) Write(metrics []telegraf.Metric) error {
 	// Inversion of this logic:
 	// https://github.com/influxdata/influxdb-observability/blob/4be04f3bc56b026c388342a0365a09f9171999a2/otel2influx/traces.go#L78
 	traceBatch := map[string]ptrace.Traces{}
-	spanBatch := map[string]ptrace.Span{}
+	// The metric each trace was created from, for routes matching its tags
+	traceMetrics := map[string]telegraf.Metric{}
+	logs := newLogsBatch()
+	var spanEventLogs []logRecord
+	var links []linkRecord
+	var metricsBatch *influx2otel.MetricsBatch
+	if o.metricsConverter != nil {
+		metricsBatch = o.metricsConverter.NewBatch()
+	}
 
 	for _, metric := range metrics {
 		o.Log.Debugf("converting otel metric: %s", metric.Name())
-		// The metric names we care about are span, span-links, logs
+		// The metric names we care about are span, span-links, logs; anything
+		// else is a span when mapped, or an OTLP metric when metrics are
+		// enabled
 		switch name := metric.Name(); name {
-		case influxcommon.MeasurementSpans:
+		case o.Schema.SpansMeasurement:
 			span, err := o.handleSpan(metric)
 			if err != nil {
 				o.Log.Error(err)
 				return err
 			}
-			traceKey := traceLookupKey(span.TraceID().String(), span.SpanID().String())
-			traces, exists := traceBatch[traceKey]
-			if !exists {
-				traces = ptrace.NewTraces()
-				traceBatch[traceKey] = traces
-				rs := traces.ResourceSpans().AppendEmpty()
-				if err := rs.Resource().Attributes().FromRaw(span.Attributes().AsRaw()); err != nil {
-					wrappedErr := fmt.Errorf("unable to add attributes from span to resource %w", err)
-					o.Log.Error(wrappedErr)
-					return wrappedErr
-				}
+			if err := o.addSpan(traceBatch, traceMetrics, metric, span); err != nil {
+				return err
 			}
-			rSpan := traces.ResourceSpans().At(0)
-			if rSpan.ScopeSpans().Len() == 0 {
-				rSpan.ScopeSpans().AppendEmpty()
+		case o.Schema.SpanLinksMeasurement:
+			link, err := o.handleSpanLink(metric)
+			if err != nil {
+				o.Log.Error(err)
+				return err
+			}
+			links = append(links, link)
+		case o.Schema.LogsMeasurement:
+			if !o.Logs && !o.LogSpanEvents {
+				continue
 			}
-			newSpan := rSpan.ScopeSpans().At(0).Spans().AppendEmpty()
-			span.CopyTo(newSpan)
-		case influxcommon.MeasurementSpanLinks:
-			spanLink, err := o.handleSpanLink(metric)
+			record, err := o.handleLogRecord(metric)
 			if err != nil {
 				o.Log.Error(err)
 				return err
 			}
-			traceKey := traceLookupKey(spanLink.TraceID().String(), spanLink.SpanID().String())
-			traces, exists := traceBatch[traceKey]
-			if !exists {
-				traces = ptrace.NewTraces()
-				traceBatch[traceKey] = traces
-				rs := traces.ResourceSpans().AppendEmpty()
-				if err := rs.Resource().Attributes().FromRaw(spanLink.Attributes().AsRaw()); err != nil {
-					return fmt.Errorf("unable to add attributes from spanlink to resource %w", err)
-				}
+			if o.Logs {
+				logs.add(record)
+			}
+			if o.LogSpanEvents && !record.SpanID().IsEmpty() {
+				spanEventLogs = append(spanEventLogs, record)
 			}
-			scopeSpans := traces.ResourceSpans().At(0).ScopeSpans().At(0)
-			spanCount := scopeSpans.Spans().Len()
-			var span ptrace.Span
-			for i := 0; i < spanCount; i++ {
-				currentSpan := scopeSpans.Spans().At(i)
-				if spanLink.SpanID().String() == currentSpan.SpanID().String() {
-					span = currentSpan
+		default:
+			if mapping, ok := o.spanMappings[name]; ok {
+				span, err := mapping.span(metric)
+				if err != nil {
+					o.Log.Error(err)
+					return err
 				}
+				if err := o.addSpan(traceBatch, traceMetrics, metric, span); err != nil {
+					return err
+				}
+				continue
 			}
-
-			emptySpanLink := span.Links().AppendEmpty()
-			spanLink.CopyTo(emptySpanLink)
-		case influxcommon.MeasurementLogs:
-			spanEvent, traceID, spanID, err := o.handleSpanEvent(metric)
-			if err != nil {
-				return err
+			if metricsBatch == nil {
+				continue
 			}
-			spanKey := spanLookupKey(traceID, spanID)
-			span, ok := spanBatch[spanKey]
-			if !ok {
-				o.Log.Debug("failed to find span with key %s", spanKey)
-				return nil
+			// A point that can't be converted would fail every retry of the
+			// batch, so it's dropped rather than returned.
+			if err := addMetric(metricsBatch, metric); err != nil {
+				o.Log.Errorf("dropping metric %s: %s", name, err)
 			}
-			emptySpanEvent := span.Events().AppendEmpty()
-			spanEvent.CopyTo(emptySpanEvent)
 		}
 	}
 
+	// Links and records are only added once every span in the batch is
+	// known, as they can be written before their span. OTLP has no links
+	// without a span, so those of spans outside the batch are dropped.
+	for _, link := range links {
+		var span ptrace.Span
+		traces, ok := traceBatch[traceLookupKey(link.traceID.String(), link.spanID.String())]
+		if ok {
+			span, ok = findSpan(traces, link.spanID)
+		}
+		if !ok {
+			o.Log.Debugf("no span %s in batch for span link", link.spanID)
+			continue
+		}
+		link.CopyTo(span.Links().AppendEmpty())
+	}
+	for _, record := range spanEventLogs {
+		var span ptrace.Span
+		traces, ok := traceBatch[traceLookupKey(record.TraceID().String(), record.SpanID().String())]
+		if ok {
+			span, ok = findSpan(traces, record.SpanID())
+		}
+		if !ok {
+			o.Log.Debugf("no span %s in batch for log record", record.SpanID())
+			continue
+		}
+		logSpanEvent(record).CopyTo(span.Events().AppendEmpty())
+	}
+
+	// Limits are part of the conversion, so they're enforced once the spans
+	// have all of their events and links
+	if o.Limits != nil {
+		o.limitSpans(traceBatch)
+	}
+	// Filters see the spans as they were written, before any processing
+	if len(o.Filters) > 0 {
+		o.filterSpans(traceBatch)
+	}
+	o.process(traceBatch)
+	if o.debugExporter != nil {
+		o.printDebug(traceBatch)
+	}
+	// Each route's traces are exported together, so that exporters such as
+	// debug see the whole batch rather than a span at a time
+	routeBatches := map[*Route]ptrace.Traces{}
 	for traceName, trace := range traceBatch {
-		o.Log.Debugf("sending trace: %s:\n%#v", traceName, trace)
-		_, err := o.Exporter.Export(context.TODO(), ptraceotlp.NewExportRequestFromTraces((trace)))
-		if err != nil {
-			o.Log.Errorf("failed to export traces %s: %s", trace, err)
+		// Routes match the resource as processed, e.g. with the detected
+		// attributes and the semantic convention renames
+		resource := pcommon.NewMap()
+		if trace.ResourceSpans().Len() > 0 {
+			resource = trace.ResourceSpans().At(0).Resource().Attributes()
+		}
+		route := o.routeFor(traceMetrics[traceName], resource)
+		batch, ok := routeBatches[route]
+		if !ok {
+			batch = ptrace.NewTraces()
+			routeBatches[route] = batch
+		}
+		trace.ResourceSpans().MoveAndAppendTo(batch.ResourceSpans())
+	}
+	for route, batch := range routeBatches {
+		routeName := "default"
+		if route != nil {
+			routeName = route.Name
+		}
+		o.Log.Debugf("sending %d spans to route %s", batch.SpanCount(), routeName)
+		if err := o.export(context.TODO(), route, batch); err != nil {
+			o.Log.Errorf("failed to export %d spans to route %s: %s", batch.SpanCount(), routeName, err)
 			return err
 		}
 	}
+	// Telegraf retries the whole batch when any route fails to export, so
+	// the generators only count it once every route has been exported
+	for _, batch := range routeBatches {
+		for _, g := range o.generators {
+			g.add(batch)
+		}
+	}
+	// Failed metrics and logs exports aren't returned, as Telegraf would
+	// retry the whole batch and so send the exported traces again. The data
+	// points and records are dropped and counted instead.
+	if metricsBatch != nil {
+		if md := metricsBatch.GetMetrics(); md.DataPointCount() > 0 {
+			o.Log.Debugf("sending %d metric data points", md.DataPointCount())
+			if err := o.exportMetrics(context.TODO(), md); err != nil {
+				o.Log.Errorf("dropping %d metric data points that failed to export: %s", md.DataPointCount(), err)
+				o.droppedMetrics.Incr(int64(md.DataPointCount()))
+			}
+		}
+	}
+	if ld := logs.logs; ld.LogRecordCount() > 0 {
+		o.Log.Debugf("sending %d log records", ld.LogRecordCount())
+		if err := o.exportLogs(context.TODO(), ld); err != nil {
+			o.Log.Errorf("dropping %d log records that failed to export: %s", ld.LogRecordCount(), err)
+			o.droppedLogs.Incr(int64(ld.LogRecordCount()))
+		}
+	}
 	return nil
 }
 
-func spanLookupKey(traceID, spanID string) string {
-	return fmt.Sprintf("%s::%s", traceID, spanID)
+// addSpan adds span to the batch, in a trace of its own along with a resource
+// built from its attributes.
+func (o *OtelTrace) addSpan(traceBatch map[string]ptrace.Traces, traceMetrics map[string]telegraf.Metric, metric telegraf.Metric, span ptrace.Span) error {
+	traceKey := traceLookupKey(span.TraceID().String(), span.SpanID().String())
+	traces, exists := traceBatch[traceKey]
+	if !exists {
+		traces = ptrace.NewTraces()
+		traceBatch[traceKey] = traces
+		rs := traces.ResourceSpans().AppendEmpty()
+		if err := rs.Resource().Attributes().FromRaw(span.Attributes().AsRaw()); err != nil {
+			wrappedErr := fmt.Errorf("unable to add attributes from span to resource %w", err)
+			o.Log.Error(wrappedErr)
+			return wrappedErr
+		}
+	}
+	traceMetrics[traceKey] = metric
+	rSpan := traces.ResourceSpans().At(0)
+	if rSpan.ScopeSpans().Len() == 0 {
+		rSpan.ScopeSpans().AppendEmpty()
+	}
+	newSpan := rSpan.ScopeSpans().At(0).Spans().AppendEmpty()
+	span.CopyTo(newSpan)
+	return nil
+}
+
+// findSpan returns the span with spanID in td.
+func findSpan(td ptrace.Traces, spanID pcommon.SpanID) (ptrace.Span, bool) {
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			spans := rs.ScopeSpans().At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				if spans.At(k).SpanID() == spanID {
+					return spans.At(k), true
+				}
+			}
+		}
+	}
+	return ptrace.Span{}, false
+}
+
+// printDebug prints the whole batch at once so that the span trees span all
+// of the traces in it.
+func (o *OtelTrace) printDebug(traceBatch map[string]ptrace.Traces) {
+	all := ptrace.NewTraces()
+	for _, trace := range traceBatch {
+		for i := 0; i < trace.ResourceSpans().Len(); i++ {
+			trace.ResourceSpans().At(i).CopyTo(all.ResourceSpans().AppendEmpty())
+		}
+	}
+	if err := o.debugExporter.Export(context.TODO(), all); err != nil {
+		o.Log.Errorf("failed to print debug output: %s", err)
+	}
 }
 
 func traceLookupKey(traceID, spanID string) string {
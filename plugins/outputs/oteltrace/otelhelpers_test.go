@@ -3,22 +3,34 @@ package oteltrace_test
 import (
 	"context"
 	"fmt"
+	"net"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/catherinetcai/telegraf-execd-otel/plugins/outputs/oteltrace"
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	semconv "go.opentelemetry.io/collector/semconv/v1.16.0"
 	"go.opentelemetry.io/otel/codes"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/trace"
 
 	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
 
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
 	influxcommon "github.com/influxdata/influxdb-observability/common"
 )
 
@@ -81,6 +93,7 @@ func generateTraces() ptrace.Traces {
 	span.SetTraceID(ptraceID)
 	span.SetSpanID(pspanID)
 	span.SetKind(ptrace.SpanKindServer)
+	span.SetStartTimestamp(pcommon.NewTimestampFromTime(time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)))
 	return td
 }
 
@@ -115,3 +128,112 @@ func generateTraceAsMetric() telegraf.Metric {
 	)
 	return mtrace
 }
+
+// recordingTracesServer accepts every export and keeps the incoming metadata
+// around so tests can inspect it.
+type recordingTracesServer struct {
+	ptraceotlp.UnimplementedGRPCServer
+
+	mu       sync.Mutex
+	requests []ptraceotlp.ExportRequest
+	metadata []metadata.MD
+	// rejectFirst fails the first n exports with an Unauthenticated error.
+	rejectFirst int
+}
+
+func (r *recordingTracesServer) Export(ctx context.Context, request ptraceotlp.ExportRequest) (ptraceotlp.ExportResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	md, _ := metadata.FromIncomingContext(ctx)
+	r.metadata = append(r.metadata, md)
+	if r.rejectFirst > 0 {
+		r.rejectFirst--
+		return ptraceotlp.NewExportResponse(), status.Error(grpccodes.Unauthenticated, "token rejected")
+	}
+	r.requests = append(r.requests, request)
+	return ptraceotlp.NewExportResponse(), nil
+}
+
+// startTracesServer serves srv on a local TCP port and returns its address.
+func startTracesServer(t *testing.T, srv ptraceotlp.GRPCServer) string {
+	t.Helper()
+	return startServer(t, func(s *grpc.Server) {
+		ptraceotlp.RegisterGRPCServer(s, srv)
+	})
+}
+
+// recordingMetricsServer accepts every metrics export and keeps the requests.
+type recordingMetricsServer struct {
+	pmetricotlp.UnimplementedGRPCServer
+
+	mu       sync.Mutex
+	requests []pmetricotlp.ExportRequest
+	metadata []metadata.MD
+}
+
+func (r *recordingMetricsServer) Export(ctx context.Context, request pmetricotlp.ExportRequest) (pmetricotlp.ExportResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	md, _ := metadata.FromIncomingContext(ctx)
+	r.metadata = append(r.metadata, md)
+	r.requests = append(r.requests, request)
+	return pmetricotlp.NewExportResponse(), nil
+}
+
+// recordingLogsServer accepts every logs export and keeps the requests.
+type recordingLogsServer struct {
+	plogotlp.UnimplementedGRPCServer
+
+	mu       sync.Mutex
+	requests []plogotlp.ExportRequest
+}
+
+func (r *recordingLogsServer) Export(_ context.Context, request plogotlp.ExportRequest) (plogotlp.ExportResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, request)
+	return plogotlp.NewExportResponse(), nil
+}
+
+// startServer serves the services added by register on a local TCP port and
+// returns its address.
+func startServer(t *testing.T, register func(*grpc.Server)) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := grpc.NewServer()
+	register(s)
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, s.Serve(lis))
+	}()
+	t.Cleanup(func() {
+		s.Stop()
+		wg.Wait()
+	})
+	return lis.Addr().String()
+}
+
+// writeTraces runs ot against a local collector, writes metrics and returns
+// every exported trace merged into one. ot logs to the test logger unless it
+// has a logger already.
+func writeTraces(t *testing.T, ot *oteltrace.OtelTrace, metrics ...telegraf.Metric) ptrace.Traces {
+	t.Helper()
+	srv := &recordingTracesServer{}
+	ot.ServiceAddress = startTracesServer(t, srv)
+	if ot.Log == nil {
+		ot.Log = &testutil.Logger{}
+	}
+	require.NoError(t, ot.Init())
+	require.NoError(t, ot.Connect())
+	require.NoError(t, ot.Write(metrics))
+	require.NoError(t, ot.Close())
+
+	td := ptrace.NewTraces()
+	for _, request := range srv.requests {
+		request.Traces().ResourceSpans().MoveAndAppendTo(td.ResourceSpans())
+	}
+	return td
+}
@@ -0,0 +1,22 @@
+package oteltrace
+
+import (
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// spanProcessor modifies the spans of a batch after conversion, before they
+// are exported. Processors run in a fixed order, whatever the order of their
+// config sections: resource detection, semantic conventions migration,
+// attribute actions, transforms, span name normalization and redaction.
+type spanProcessor interface {
+	processTraces(td ptrace.Traces)
+}
+
+// process runs every processor over each trace of the batch.
+func (o *OtelTrace) process(traceBatch map[string]ptrace.Traces) {
+	for _, traces := range traceBatch {
+		for _, p := range o.processors {
+			p.processTraces(traces)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package oteltrace
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+const (
+	redactionModeMask   = "mask"
+	redactionModeRemove = "remove"
+
+	redactionMask = "****"
+
+	// redactionCountAttribute is set on every processed span to the number of
+	// its, its events' and its links' attributes that were redacted, and on
+	// every resource to the number of its own.
+	redactionCountAttribute = "redaction.count"
+)
+
+// Built-in patterns for common PII, selected by name.
+var redactionPatterns = map[string]string{
+	"email":       `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`,
+	"credit_card": `\b(?:\d[ -]?){12,18}\d\b`,
+	"auth_token":  `(?i)(?:\b(?:bearer|basic)\s+[A-Za-z0-9\-._~+/]+=*|\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+|\b(?:access_token|api_key|apikey|password|secret|token)=[^&\s]+)`,
+}
+
+// Checks a match of a built-in pattern must pass to be redacted, for the
+// patterns that would otherwise catch unrelated values such as IDs and
+// timestamps.
+var redactionValidators = map[string]func(string) bool{
+	"credit_card": luhnValid,
+}
+
+var defaultRedactionPatterns = []string{"auth_token", "credit_card", "email"}
+
+// redactionPattern is a blocked pattern along with the check its matches must
+// pass, if any.
+type redactionPattern struct {
+	re    *regexp.Regexp
+	valid func(string) bool
+}
+
+// mask replaces the valid matches of the pattern in value.
+func (p redactionPattern) mask(value string) string {
+	if p.valid == nil {
+		return p.re.ReplaceAllLiteralString(value, redactionMask)
+	}
+	return p.re.ReplaceAllStringFunc(value, func(match string) string {
+		if !p.valid(match) {
+			return match
+		}
+		return redactionMask
+	})
+}
+
+// luhnValid reports whether the digits of number, ignoring spaces and dashes,
+// have a valid Luhn check digit, as every payment card number does.
+func luhnValid(number string) bool {
+	sum, digits := 0, 0
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+		d := int(c - '0')
+		if digits%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		digits++
+	}
+	return digits > 0 && sum%10 == 0
+}
+
+// RedactionConfig scrubs PII from the attributes of every span, its events
+// and links, and of its resource. Keys not in AllowedKeys are removed from
+// all but the resource, when it's set, so service.name and the other
+// resource attributes survive. Values matching a blocked pattern have the
+// matches masked, or are removed altogether in the remove mode.
+type RedactionConfig struct {
+	AllowedKeys     []string `toml:"allowed_keys"`
+	IgnoredKeys     []string `toml:"ignored_keys"`
+	BlockedValues   []string `toml:"blocked_values"`
+	BuiltinPatterns []string `toml:"builtin_patterns"`
+	Mode            string   `toml:"mode"`
+
+	blocked []redactionPattern
+}
+
+func (c *RedactionConfig) init() error {
+	if c.Mode == "" {
+		c.Mode = redactionModeMask
+	}
+	if c.Mode != redactionModeMask && c.Mode != redactionModeRemove {
+		return fmt.Errorf("invalid redaction mode %q", c.Mode)
+	}
+	if c.BuiltinPatterns == nil {
+		c.BuiltinPatterns = defaultRedactionPatterns
+	}
+	for _, name := range c.BuiltinPatterns {
+		pattern, ok := redactionPatterns[name]
+		if !ok {
+			return fmt.Errorf("unknown redaction pattern %q", name)
+		}
+		c.blocked = append(c.blocked, redactionPattern{
+			re:    regexp.MustCompile(pattern),
+			valid: redactionValidators[name],
+		})
+	}
+	for _, pattern := range c.BlockedValues {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid redaction pattern %q: %w", pattern, err)
+		}
+		c.blocked = append(c.blocked, redactionPattern{re: re})
+	}
+	return nil
+}
+
+func (c *RedactionConfig) processTraces(td ptrace.Traces) {
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		resource := rs.Resource().Attributes()
+		count := c.redact(resource, false)
+		resource.PutInt(redactionCountAttribute, int64(count))
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			spans := rs.ScopeSpans().At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				c.redactSpan(spans.At(k))
+			}
+		}
+	}
+}
+
+func (c *RedactionConfig) redactSpan(span ptrace.Span) {
+	count := c.redact(span.Attributes(), true)
+	for i := 0; i < span.Events().Len(); i++ {
+		count += c.redact(span.Events().At(i).Attributes(), true)
+	}
+	for i := 0; i < span.Links().Len(); i++ {
+		count += c.redact(span.Links().At(i).Attributes(), true)
+	}
+	span.Attributes().PutInt(redactionCountAttribute, int64(count))
+}
+
+// redact scrubs attributes and returns the number of attributes it removed
+// or masked. Keys outside AllowedKeys are only removed when allowlist is set.
+func (c *RedactionConfig) redact(attributes pcommon.Map, allowlist bool) int {
+	count := 0
+	attributes.RemoveIf(func(k string, v pcommon.Value) bool {
+		if slices.Contains(c.IgnoredKeys, k) {
+			return false
+		}
+		if allowlist && len(c.AllowedKeys) > 0 && !slices.Contains(c.AllowedKeys, k) {
+			count++
+			return true
+		}
+		value := v.AsString()
+		masked := value
+		for _, pattern := range c.blocked {
+			masked = pattern.mask(masked)
+		}
+		if masked == value {
+			return false
+		}
+		count++
+		if c.Mode == redactionModeRemove {
+			return true
+		}
+		v.SetStr(masked)
+		return false
+	})
+	return count
+}
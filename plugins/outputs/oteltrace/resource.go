@@ -0,0 +1,162 @@
+package oteltrace
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	semconv "go.opentelemetry.io/collector/semconv/v1.16.0"
+)
+
+const (
+	detectorHost      = "host"
+	detectorOS        = "os"
+	detectorContainer = "container"
+	detectorK8s       = "k8s"
+
+	defaultMachineIDFile = "/etc/machine-id"
+	defaultCgroupFile    = "/proc/self/cgroup"
+)
+
+var defaultDetectors = []string{detectorHost, detectorOS, detectorContainer, detectorK8s}
+
+// Environment variables the Kubernetes downward API is usually configured to
+// set, in order of preference.
+var k8sEnvironment = []struct {
+	attribute string
+	variables []string
+}{
+	{semconv.AttributeK8SPodName, []string{"K8S_POD_NAME", "POD_NAME"}},
+	{semconv.AttributeK8SPodUID, []string{"K8S_POD_UID", "POD_UID"}},
+	{semconv.AttributeK8SNamespaceName, []string{"K8S_NAMESPACE_NAME", "POD_NAMESPACE"}},
+	{semconv.AttributeK8SNodeName, []string{"K8S_NODE_NAME", "NODE_NAME"}},
+}
+
+// The last 64 hex characters of a cgroup path, whatever the runtime's
+// naming: /docker/<id>, /kubepods/.../<id>, cri-containerd-<id>.scope...
+var containerIDPattern = regexp.MustCompile(`([0-9a-f]{64})(?:\.scope)?$`)
+
+// ResourceDetection merges attributes describing where Telegraf runs into
+// every exported resource. Detectors run once at Init; the static Attributes
+// take precedence over detected values. Unless Override is set, attributes
+// already on a resource are kept.
+type ResourceDetection struct {
+	Detectors     []string          `toml:"detectors"`
+	Attributes    map[string]string `toml:"attributes"`
+	Override      bool              `toml:"override"`
+	MachineIDFile string            `toml:"machine_id_file"`
+	CgroupFile    string            `toml:"cgroup_file"`
+
+	detected pcommon.Map
+}
+
+func (d *ResourceDetection) init(log telegraf.Logger) error {
+	if d.Detectors == nil {
+		d.Detectors = defaultDetectors
+	}
+	if d.MachineIDFile == "" {
+		d.MachineIDFile = defaultMachineIDFile
+	}
+	if d.CgroupFile == "" {
+		d.CgroupFile = defaultCgroupFile
+	}
+	d.detected = pcommon.NewMap()
+	for _, detector := range d.Detectors {
+		var err error
+		switch detector {
+		case detectorHost:
+			err = d.detectHost()
+		case detectorOS:
+			d.detectOS()
+		case detectorContainer:
+			err = d.detectContainer()
+		case detectorK8s:
+			d.detectK8s()
+		default:
+			return fmt.Errorf("invalid resource detector %q", detector)
+		}
+		// Not every detector applies everywhere, so a failing one doesn't
+		// stop the others
+		if err != nil {
+			log.Debugf("resource detector %s: %s", detector, err)
+		}
+	}
+	for key, value := range d.Attributes {
+		d.detected.PutStr(key, value)
+	}
+	return nil
+}
+
+func (d *ResourceDetection) detectHost() error {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+	d.detected.PutStr(semconv.AttributeHostName, hostname)
+	machineID, err := os.ReadFile(d.MachineIDFile)
+	if err != nil {
+		return err
+	}
+	if id := strings.TrimSpace(string(machineID)); id != "" {
+		d.detected.PutStr(semconv.AttributeHostID, id)
+	}
+	return nil
+}
+
+func (d *ResourceDetection) detectOS() {
+	osType := runtime.GOOS
+	if osType == "dragonfly" {
+		osType = semconv.AttributeOSTypeDragonflyBSD
+	}
+	d.detected.PutStr(semconv.AttributeOSType, osType)
+}
+
+func (d *ResourceDetection) detectContainer() error {
+	f, err := os.Open(d.CgroupFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// hierarchy-ID:controller-list:cgroup-path
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if match := containerIDPattern.FindStringSubmatch(parts[2]); match != nil {
+			d.detected.PutStr(semconv.AttributeContainerID, match[1])
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+func (d *ResourceDetection) detectK8s() {
+	for _, env := range k8sEnvironment {
+		for _, variable := range env.variables {
+			if value := os.Getenv(variable); value != "" {
+				d.detected.PutStr(env.attribute, value)
+				break
+			}
+		}
+	}
+}
+
+func (d *ResourceDetection) processTraces(td ptrace.Traces) {
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		attributes := td.ResourceSpans().At(i).Resource().Attributes()
+		d.detected.Range(func(key string, value pcommon.Value) bool {
+			if _, ok := attributes.Get(key); !ok || d.Override {
+				value.CopyTo(attributes.PutEmpty(key))
+			}
+			return true
+		})
+	}
+}
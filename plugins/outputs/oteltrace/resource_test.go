@@ -0,0 +1,69 @@
+package oteltrace_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/catherinetcai/telegraf-execd-otel/plugins/outputs/oteltrace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const containerID = "3f2b6c0e8a1d4f5e9b7c2a6d8e0f1a3b5c7d9e1f2a4b6c8d0e2f4a6b8c0d2e4f"
+
+func TestOtelTraceResourceDetection(t *testing.T) {
+	dir := t.TempDir()
+	machineIDFile := filepath.Join(dir, "machine-id")
+	require.NoError(t, os.WriteFile(machineIDFile, []byte("b1946ac92492d2347c6235b4d2611184\n"), 0o600))
+	cgroupFile := filepath.Join(dir, "cgroup")
+	require.NoError(t, os.WriteFile(cgroupFile, []byte(
+		"12:pids:/\n"+
+			"11:memory:/kubepods/burstable/pod7c1d/"+containerID+"\n"+
+			"0::/\n"), 0o600))
+	t.Setenv("K8S_POD_NAME", "checkout-7d9f")
+	t.Setenv("POD_NAMESPACE", "shop")
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+
+	ot := &oteltrace.OtelTrace{
+		Resource: &oteltrace.ResourceDetection{
+			Attributes:    map[string]string{"deployment.environment": "production", "k8s.pod.name": "static"},
+			MachineIDFile: machineIDFile,
+			CgroupFile:    cgroupFile,
+		},
+	}
+	td := writeTraces(t, ot, attributesSpan("pay", `{"service.name":"checkout","host.name":"app-1"}`))
+	require.Equal(t, 1, td.SpanCount())
+	assert.Equal(t, map[string]any{
+		"service.name":           "checkout",
+		"host.name":              "app-1",
+		"host.id":                "b1946ac92492d2347c6235b4d2611184",
+		"os.type":                runtime.GOOS,
+		"container.id":           containerID,
+		"k8s.pod.name":           "static",
+		"k8s.namespace.name":     "shop",
+		"deployment.environment": "production",
+	}, td.ResourceSpans().At(0).Resource().Attributes().AsRaw())
+
+	ot = &oteltrace.OtelTrace{
+		Resource: &oteltrace.ResourceDetection{
+			Detectors: []string{"host"},
+			Override:  true,
+			// Missing files only leave out what they'd have detected
+			MachineIDFile: filepath.Join(dir, "missing"),
+		},
+	}
+	td = writeTraces(t, ot, attributesSpan("pay", `{"service.name":"checkout","host.name":"app-1"}`))
+	require.Equal(t, 1, td.SpanCount())
+	assert.Equal(t, map[string]any{
+		"service.name": "checkout",
+		"host.name":    hostname,
+	}, td.ResourceSpans().At(0).Resource().Attributes().AsRaw())
+}
+
+func TestOtelTraceResourceDetectionInit(t *testing.T) {
+	ot := &oteltrace.OtelTrace{Resource: &oteltrace.ResourceDetection{Detectors: []string{"ec2"}}}
+	assert.ErrorContains(t, ot.Init(), `invalid resource detector "ec2"`)
+}
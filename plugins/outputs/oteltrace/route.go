@@ -0,0 +1,92 @@
+package oteltrace
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/influxdata/telegraf"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"google.golang.org/grpc/metadata"
+)
+
+// Route sends the spans whose resource attribute or tag Key has one of Values
+// to their own endpoint or named exporters, and/or with their own headers.
+// Spans that don't match any route use the plugin's top level settings.
+type Route struct {
+	Name           string            `toml:"name"`
+	Key            string            `toml:"key"`
+	Values         []string          `toml:"values"`
+	ServiceAddress string            `toml:"service_address"`
+	Exporters      []string          `toml:"exporters"`
+	Headers        map[string]string `toml:"headers"`
+
+	exporters []*namedExporter
+}
+
+func (r *Route) init(defaultHeaders map[string]string) error {
+	if r.Name == "" {
+		return fmt.Errorf("route name is required")
+	}
+	if r.Key == "" {
+		return fmt.Errorf("route %s: key is required", r.Name)
+	}
+	if len(r.Values) == 0 {
+		return fmt.Errorf("route %s: at least one value is required", r.Name)
+	}
+	if r.ServiceAddress != "" && len(r.Exporters) > 0 {
+		return fmt.Errorf("route %s: service_address and exporters are mutually exclusive", r.Name)
+	}
+	// Route headers are layered on top of the default ones so that shared
+	// settings don't have to be repeated in every route.
+	headers := make(map[string]string, len(defaultHeaders)+len(r.Headers))
+	for k, v := range defaultHeaders {
+		headers[k] = v
+	}
+	for k, v := range r.Headers {
+		headers[k] = v
+	}
+	r.Headers = headers
+	return nil
+}
+
+func (r *Route) matches(metric telegraf.Metric, resource pcommon.Map) bool {
+	if value, ok := resource.Get(r.Key); ok {
+		return slices.Contains(r.Values, value.AsString())
+	}
+	if value, ok := metric.GetTag(r.Key); ok {
+		return slices.Contains(r.Values, value)
+	}
+	return false
+}
+
+// routeFor returns the first route matching the span, or nil for the default
+// route.
+func (o *OtelTrace) routeFor(metric telegraf.Metric, resource pcommon.Map) *Route {
+	for _, route := range o.Routes {
+		if route.matches(metric, resource) {
+			return route
+		}
+	}
+	return nil
+}
+
+func (o *OtelTrace) export(ctx context.Context, route *Route, td ptrace.Traces) error {
+	headers, exporters := o.Headers, o.exporters
+	if route != nil {
+		headers = route.Headers
+		if len(route.exporters) > 0 {
+			exporters = route.exporters
+		}
+	}
+	if len(headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(headers))
+	}
+	if len(exporters) == 0 {
+		_, err := o.Exporter.Export(ctx, ptraceotlp.NewExportRequestFromTraces(td))
+		return err
+	}
+	return o.fanOut(ctx, exporters, td)
+}
@@ -0,0 +1,135 @@
+package oteltrace_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/catherinetcai/telegraf-execd-otel/plugins/outputs/oteltrace"
+	influxcommon "github.com/influxdata/influxdb-observability/common"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOtelTraceRoutes(t *testing.T) {
+	defaultServer := &recordingTracesServer{}
+	teamBServer := &recordingTracesServer{}
+	ot := &oteltrace.OtelTrace{
+		ServiceAddress: startTracesServer(t, defaultServer),
+		Headers:        map[string]string{"X-Scope-OrgID": "shared"},
+		Routes: []*oteltrace.Route{
+			{
+				Name:    "team-a",
+				Key:     "k8s.namespace.name",
+				Values:  []string{"team-a"},
+				Headers: map[string]string{"X-Scope-OrgID": "team-a"},
+			},
+			{
+				Name:           "team-b",
+				Key:            "k8s.namespace.name",
+				Values:         []string{"team-b", "team-b-staging"},
+				ServiceAddress: startTracesServer(t, teamBServer),
+			},
+		},
+		Log: &testutil.Logger{},
+	}
+	require.NoError(t, ot.Init())
+	require.NoError(t, ot.Connect())
+	defer ot.Close()
+
+	teamA := generateTraceAsMetric()
+	teamA.AddTag("k8s.namespace.name", "team-a")
+	teamB := generateTraceAsMetric()
+	teamB.AddTag("k8s.namespace.name", "team-b-staging")
+	require.NoError(t, ot.Write([]telegraf.Metric{teamA}))
+	require.NoError(t, ot.Write([]telegraf.Metric{teamB}))
+	require.NoError(t, ot.Write([]telegraf.Metric{generateTraceAsMetric()}))
+
+	require.Len(t, defaultServer.metadata, 2)
+	assert.Equal(t, []string{"team-a"}, defaultServer.metadata[0].Get("X-Scope-OrgID"))
+	assert.Equal(t, []string{"shared"}, defaultServer.metadata[1].Get("X-Scope-OrgID"))
+	require.Len(t, teamBServer.metadata, 1)
+	assert.Equal(t, []string{"shared"}, teamBServer.metadata[0].Get("X-Scope-OrgID"))
+}
+
+func TestOtelTraceRoutesInit(t *testing.T) {
+	ot := &oteltrace.OtelTrace{
+		Routes: []*oteltrace.Route{{Name: "team-a", Key: "k8s.namespace.name"}},
+	}
+	assert.ErrorContains(t, ot.Init(), "at least one value")
+}
+
+func TestOtelTraceRoutesAfterProcessing(t *testing.T) {
+	defaultServer := &recordingTracesServer{}
+	prodServer := &recordingTracesServer{}
+	ot := &oteltrace.OtelTrace{
+		ServiceAddress: startTracesServer(t, defaultServer),
+		Resource: &oteltrace.ResourceDetection{
+			Detectors:  []string{},
+			Attributes: map[string]string{"deployment.environment": "prod"},
+		},
+		Routes: []*oteltrace.Route{{
+			Name:           "prod",
+			Key:            "deployment.environment",
+			Values:         []string{"prod"},
+			ServiceAddress: startTracesServer(t, prodServer),
+		}},
+		Log: &testutil.Logger{},
+	}
+	require.NoError(t, ot.Init())
+	require.NoError(t, ot.Connect())
+	defer ot.Close()
+
+	// The route key is only on the resource once it's been detected
+	require.NoError(t, ot.Write([]telegraf.Metric{generateTraceAsMetric()}))
+	assert.Empty(t, defaultServer.requests)
+	require.Len(t, prodServer.requests, 1)
+	assert.Equal(t, 1, prodServer.requests[0].Traces().SpanCount())
+}
+
+func TestOtelTraceRoutesSpanLinks(t *testing.T) {
+	defaultServer := &recordingTracesServer{}
+	teamAServer := &recordingTracesServer{}
+	ot := &oteltrace.OtelTrace{
+		ServiceAddress: startTracesServer(t, defaultServer),
+		Routes: []*oteltrace.Route{{
+			Name:           "team-a",
+			Key:            "k8s.namespace.name",
+			Values:         []string{"team-a"},
+			ServiceAddress: startTracesServer(t, teamAServer),
+		}},
+		Log: &testutil.Logger{},
+	}
+	require.NoError(t, ot.Init())
+	require.NoError(t, ot.Connect())
+	defer ot.Close()
+
+	link := func(spanID string) telegraf.Metric {
+		return metric.New(influxcommon.MeasurementSpanLinks,
+			map[string]string{
+				influxcommon.AttributeTraceID:       "00000000000000000000000000000001",
+				influxcommon.AttributeSpanID:        spanID,
+				influxcommon.AttributeLinkedTraceID: "00000000000000000000000000000002",
+				influxcommon.AttributeLinkedSpanID:  "000000000000000b",
+			},
+			map[string]interface{}{influxcommon.AttributeAttributes: `{"messaging.operation":"receive"}`},
+			time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC),
+		)
+	}
+	span := generateTraceAsMetric()
+	span.AddTag("k8s.namespace.name", "team-a")
+	// Links follow their span's route, even when written before it. The link
+	// of a span outside the batch has nothing to be exported with.
+	require.NoError(t, ot.Write([]telegraf.Metric{link("000000000000000a"), span, link("000000000000000c")}))
+	assert.Empty(t, defaultServer.requests)
+	require.Len(t, teamAServer.requests, 1)
+	td := teamAServer.requests[0].Traces()
+	require.Equal(t, 1, td.SpanCount())
+	links := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Links()
+	require.Equal(t, 1, links.Len())
+	assert.Equal(t, "00000000000000000000000000000002", links.At(0).TraceID().String())
+	assert.Equal(t, "000000000000000b", links.At(0).SpanID().String())
+	assert.Equal(t, map[string]any{"messaging.operation": "receive"}, links.At(0).Attributes().AsRaw())
+}
@@ -0,0 +1,92 @@
+package oteltrace
+
+import (
+	"fmt"
+
+	influxcommon "github.com/influxdata/influxdb-observability/common"
+	semconv "go.opentelemetry.io/collector/semconv/v1.16.0"
+)
+
+// Schema names the measurements and columns the otel2influx points were
+// written with, for pipelines renaming them with name_override, name_prefix
+// or the rename processor. Empty names keep otel2influx's.
+type Schema struct {
+	SpansMeasurement     string `toml:"spans_measurement"`
+	SpanLinksMeasurement string `toml:"span_links_measurement"`
+	LogsMeasurement      string `toml:"logs_measurement"`
+
+	TraceID       string `toml:"trace_id"`
+	SpanID        string `toml:"span_id"`
+	ParentSpanID  string `toml:"parent_span_id"`
+	Name          string `toml:"name"`
+	Kind          string `toml:"kind"`
+	EndTime       string `toml:"end_time"`
+	StatusCode    string `toml:"status_code"`
+	StatusMessage string `toml:"status_message"`
+
+	// columns are every tag and field of the spans measurement written from
+	// the span itself rather than from its attributes.
+	columns map[string]bool
+}
+
+func (s *Schema) init() error {
+	defaults := []struct {
+		value    *string
+		key      string
+		fallback string
+	}{
+		{&s.SpansMeasurement, "spans_measurement", influxcommon.MeasurementSpans},
+		{&s.SpanLinksMeasurement, "span_links_measurement", influxcommon.MeasurementSpanLinks},
+		{&s.LogsMeasurement, "logs_measurement", influxcommon.MeasurementLogs},
+		{&s.TraceID, "trace_id", influxcommon.AttributeTraceID},
+		{&s.SpanID, "span_id", influxcommon.AttributeSpanID},
+		{&s.ParentSpanID, "parent_span_id", influxcommon.AttributeParentSpanID},
+		{&s.Name, "name", influxcommon.AttributeSpanName},
+		{&s.Kind, "kind", influxcommon.AttributeSpanKind},
+		{&s.EndTime, "end_time", influxcommon.AttributeEndTimeUnixNano},
+		{&s.StatusCode, "status_code", semconv.OtelStatusCode},
+		{&s.StatusMessage, "status_message", semconv.OtelStatusDescription},
+	}
+	for _, d := range defaults {
+		if *d.value == "" {
+			*d.value = d.fallback
+		}
+	}
+
+	measurements := map[string]string{}
+	for _, d := range defaults[:3] {
+		if other, ok := measurements[*d.value]; ok {
+			return fmt.Errorf("schema: %s and %s both name measurement %s", other, d.key, *d.value)
+		}
+		measurements[*d.value] = d.key
+	}
+
+	// The columns that can't be renamed are claimed first so that a renamed
+	// column can't shadow them
+	columns := map[string]string{
+		influxcommon.AttributeTraceState:             influxcommon.AttributeTraceState,
+		influxcommon.AttributeDurationNano:           influxcommon.AttributeDurationNano,
+		influxcommon.AttributeDroppedAttributesCount: influxcommon.AttributeDroppedAttributesCount,
+		influxcommon.AttributeDroppedEventsCount:     influxcommon.AttributeDroppedEventsCount,
+		influxcommon.AttributeDroppedLinksCount:      influxcommon.AttributeDroppedLinksCount,
+		influxcommon.AttributeFlags:                  influxcommon.AttributeFlags,
+		influxcommon.AttributeAttributes:             influxcommon.AttributeAttributes,
+	}
+	for _, d := range defaults[3:] {
+		if other, ok := columns[*d.value]; ok {
+			return fmt.Errorf("schema: %s and %s both name column %s", other, d.key, *d.value)
+		}
+		columns[*d.value] = d.key
+	}
+	s.columns = make(map[string]bool, len(columns))
+	for column := range columns {
+		s.columns[column] = true
+	}
+	return nil
+}
+
+// measurement reports whether name is one of the measurements converted
+// according to the schema.
+func (s *Schema) measurement(name string) bool {
+	return name == s.SpansMeasurement || name == s.SpanLinksMeasurement || name == s.LogsMeasurement
+}
@@ -0,0 +1,81 @@
+package oteltrace_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/catherinetcai/telegraf-execd-otel/plugins/outputs/oteltrace"
+	influxcommon "github.com/influxdata/influxdb-observability/common"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestOtelTraceSchema(t *testing.T) {
+	start := time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
+	span := metric.New("otel_spans",
+		map[string]string{
+			"tid":     fmt.Sprintf("%032x", 1),
+			"sid":     fmt.Sprintf("%016x", 2),
+			"service": "checkout",
+		},
+		map[string]interface{}{
+			"parent":                         fmt.Sprintf("%016x", 3),
+			"operation":                      "pay",
+			"span_kind":                      ptrace.SpanKindClient.String(),
+			"end":                            start.Add(time.Second).UnixNano(),
+			"status":                         "STATUS_CODE_ERROR",
+			"status_message":                 "declined",
+			influxcommon.AttributeAttributes: `{"http.status_code":402}`,
+		},
+		start,
+	)
+	ot := &oteltrace.OtelTrace{
+		Schema: oteltrace.Schema{
+			SpansMeasurement: "otel_spans",
+			TraceID:          "tid",
+			SpanID:           "sid",
+			ParentSpanID:     "parent",
+			Name:             "operation",
+			Kind:             "span_kind",
+			EndTime:          "end",
+			StatusCode:       "status",
+			StatusMessage:    "status_message",
+		},
+	}
+	td := writeTraces(t, ot, span)
+	require.Equal(t, 1, td.SpanCount())
+	got := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0)
+	assert.Equal(t, fmt.Sprintf("%032x", 1), got.TraceID().String())
+	assert.Equal(t, fmt.Sprintf("%016x", 2), got.SpanID().String())
+	assert.Equal(t, fmt.Sprintf("%016x", 3), got.ParentSpanID().String())
+	assert.Equal(t, "pay", got.Name())
+	assert.Equal(t, ptrace.SpanKindClient, got.Kind())
+	assert.Equal(t, time.Second, got.EndTimestamp().AsTime().Sub(got.StartTimestamp().AsTime()))
+	assert.Equal(t, ptrace.StatusCodeError, got.Status().Code())
+	assert.Equal(t, "declined", got.Status().Message())
+	assert.Equal(t, map[string]any{"http.status_code": int64(402), "service": "checkout"}, got.Attributes().AsRaw())
+
+	// The default measurement is no longer converted
+	td = writeTraces(t, ot, span, generateTraceAsMetric())
+	assert.Equal(t, 1, td.SpanCount())
+}
+
+func TestOtelTraceSchemaInit(t *testing.T) {
+	ot := &oteltrace.OtelTrace{Schema: oteltrace.Schema{LogsMeasurement: "spans"}}
+	assert.ErrorContains(t, ot.Init(), "schema: spans_measurement and logs_measurement both name measurement spans")
+
+	ot = &oteltrace.OtelTrace{Schema: oteltrace.Schema{SpanID: "id", ParentSpanID: "id"}}
+	assert.ErrorContains(t, ot.Init(), "schema: span_id and parent_span_id both name column id")
+
+	ot = &oteltrace.OtelTrace{Schema: oteltrace.Schema{Name: "attributes"}}
+	assert.ErrorContains(t, ot.Init(), "schema: attributes and name both name column attributes")
+
+	ot = &oteltrace.OtelTrace{
+		Schema:       oteltrace.Schema{SpansMeasurement: "otel_spans"},
+		SpanMappings: []*oteltrace.SpanMapping{{Measurement: "otel_spans"}},
+	}
+	assert.ErrorContains(t, ot.Init(), "span mapping otel_spans: measurement is already converted")
+}
@@ -0,0 +1,257 @@
+package oteltrace
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	semconv "go.opentelemetry.io/collector/semconv/v1.16.0"
+	semconv117 "go.opentelemetry.io/collector/semconv/v1.17.0"
+	semconv118 "go.opentelemetry.io/collector/semconv/v1.18.0"
+	semconv121 "go.opentelemetry.io/collector/semconv/v1.21.0"
+	semconv122 "go.opentelemetry.io/collector/semconv/v1.22.0"
+	semconv125 "go.opentelemetry.io/collector/semconv/v1.25.0"
+)
+
+const defaultSemconvTarget = "1.25.0"
+
+// semconvLegacyVersion is the oldest version with translation tables, which
+// the legacy renames migrate to.
+var semconvLegacyVersion = [3]int{1, 16, 0}
+
+// semconvVersions are the versions that can be migrated to, with the
+// attribute keys they define.
+var semconvVersions = map[string][]func() []string{
+	"1.16.0": {semconv.GetResourceSemanticConventionAttributeNames, semconv.GetTraceSemanticConventionAttributeNames},
+	"1.17.0": {semconv117.GetResourceSemanticConventionAttributeNames, semconv117.GetTraceSemanticConventionAttributeNames, semconv117.GetEventSemanticConventionAttributeNames},
+	"1.18.0": {semconv118.GetResourceSemanticConventionAttributeNames, semconv118.GetTraceSemanticConventionAttributeNames, semconv118.GetEventSemanticConventionAttributeNames},
+	"1.21.0": {semconv121.GetResourceSemanticConventionAttributeNames, semconv121.GetTraceSemanticConventionAttributeNames, semconv121.GetEventSemanticConventionAttributeNames},
+	"1.22.0": {semconv122.GetResourceSemanticConventionAttributeNames, semconv122.GetTraceSemanticConventionAttributeNames, semconv122.GetEventSemanticConventionAttributeNames},
+	"1.25.0": {
+		semconv125.GetResourceSemanticConventionAttributeNames,
+		semconv125.GetTraceSemanticConventionAttributeNames,
+		semconv125.GetEventSemanticConventionAttributeNames,
+		semconv125.GetAttribute_groupSemanticConventionAttributeNames,
+	},
+}
+
+// semconvLegacyRenames are keys from before 1.16.0 that clients still send.
+// When migrating from an older version they are renamed to their 1.16.0 keys
+// before any other step.
+var semconvLegacyRenames = map[string]string{
+	"net.peer.ip": "net.sock.peer.addr",
+	"net.host.ip": "net.sock.host.addr",
+}
+
+// semconvRenames are the attribute keys renamed by each version, after the
+// schema files published with the semantic conventions.
+var semconvRenames = []struct {
+	version string
+	renames map[string]string
+}{
+	{"1.17.0", map[string]string{
+		"messaging.destination":                           "messaging.destination.name",
+		"messaging.destination_kind":                      "messaging.destination.kind",
+		"messaging.temp_destination":                      "messaging.destination.temporary",
+		"messaging.consumer_id":                           "messaging.consumer.id",
+		"messaging.message_id":                            "messaging.message.id",
+		"messaging.conversation_id":                       "messaging.message.conversation_id",
+		"messaging.message_payload_size_bytes":            "messaging.message.payload_size_bytes",
+		"messaging.message_payload_compressed_size_bytes": "messaging.message.payload_compressed_size_bytes",
+		"messaging.kafka.message_key":                     "messaging.kafka.message.key",
+		"messaging.kafka.consumer_group":                  "messaging.kafka.consumer.group",
+		"messaging.kafka.partition":                       "messaging.kafka.destination.partition",
+		"messaging.kafka.tombstone":                       "messaging.kafka.message.tombstone",
+		"messaging.rabbitmq.routing_key":                  "messaging.rabbitmq.destination.routing_key",
+		"messaging.rocketmq.message_type":                 "messaging.rocketmq.message.type",
+		"messaging.rocketmq.message_tag":                  "messaging.rocketmq.message.tag",
+		"messaging.rocketmq.message_keys":                 "messaging.rocketmq.message.keys",
+		"messaging.rocketmq.message_group":                "messaging.rocketmq.message.group",
+		"messaging.rocketmq.delay_time_level":             "messaging.rocketmq.message.delay_time_level",
+		"messaging.rocketmq.delivery_timestamp":           "messaging.rocketmq.message.delivery_timestamp",
+	}},
+	{"1.19.0", map[string]string{
+		"faas.execution":  "faas.invocation_id",
+		"faas.id":         "cloud.resource_id",
+		"http.user_agent": "user_agent.original",
+	}},
+	{"1.20.0", map[string]string{
+		"net.app.protocol.name":    "net.protocol.name",
+		"net.app.protocol.version": "net.protocol.version",
+	}},
+	{"1.21.0", map[string]string{
+		"http.method":                  "http.request.method",
+		"http.status_code":             "http.response.status_code",
+		"http.scheme":                  "url.scheme",
+		"http.url":                     "url.full",
+		"http.request_content_length":  "http.request.body.size",
+		"http.response_content_length": "http.response.body.size",
+		"http.client_ip":               "client.address",
+		"net.host.name":                "server.address",
+		"net.host.port":                "server.port",
+		"net.protocol.name":            "network.protocol.name",
+		"net.protocol.version":         "network.protocol.version",
+		"net.transport":                "network.transport",
+		"messaging.kafka.client_id":    "messaging.client_id",
+		"messaging.rocketmq.client_id": "messaging.client_id",
+	}},
+	{"1.22.0", map[string]string{
+		"net.sock.peer.addr":                              "network.peer.address",
+		"net.sock.peer.port":                              "network.peer.port",
+		"net.sock.host.addr":                              "network.local.address",
+		"net.sock.host.port":                              "network.local.port",
+		"messaging.message.payload_size_bytes":            "messaging.message.body.size",
+		"messaging.message.payload_compressed_size_bytes": "messaging.message.envelope.size",
+	}},
+	{"1.23.0", map[string]string{
+		"http.resend_count": "http.request.resend_count",
+	}},
+	{"1.25.0", map[string]string{
+		"messaging.kafka.destination.partition": "messaging.destination.partition.id",
+	}},
+}
+
+// SemconvMigration rewrites attribute keys written with the semantic
+// conventions of version From to the ones of version To, and sets the
+// resources' schema URL to To's.
+type SemconvMigration struct {
+	From string `toml:"from"`
+	To   string `toml:"to"`
+
+	renames   []map[string]string
+	known     map[string]bool
+	prefixes  map[string]bool
+	schemaURL string
+	reported  map[string]bool
+	log       telegraf.Logger
+}
+
+func (m *SemconvMigration) init(log telegraf.Logger) error {
+	if m.To == "" {
+		m.To = defaultSemconvTarget
+	}
+	from, err := parseSemconvVersion(m.From)
+	if err != nil {
+		return fmt.Errorf("semconv migration: invalid from version: %w", err)
+	}
+	to, err := parseSemconvVersion(m.To)
+	if err != nil {
+		return fmt.Errorf("semconv migration: invalid to version: %w", err)
+	}
+	names, ok := semconvVersions[m.To]
+	if !ok {
+		return fmt.Errorf("semconv migration: unsupported to version %s", m.To)
+	}
+	if compareSemconvVersions(from, to) > 0 {
+		return fmt.Errorf("semconv migration: can't migrate from %s back to %s", m.From, m.To)
+	}
+
+	m.renames = nil
+	if compareSemconvVersions(from, semconvLegacyVersion) < 0 {
+		m.renames = append(m.renames, semconvLegacyRenames)
+	}
+	for _, step := range semconvRenames {
+		version, _ := parseSemconvVersion(step.version)
+		if compareSemconvVersions(version, from) > 0 && compareSemconvVersions(version, to) <= 0 {
+			m.renames = append(m.renames, step.renames)
+		}
+	}
+	m.known = map[string]bool{}
+	m.prefixes = map[string]bool{}
+	for _, get := range names {
+		for _, name := range get() {
+			m.known[name] = true
+			prefix, _, _ := strings.Cut(name, ".")
+			m.prefixes[prefix] = true
+		}
+	}
+	m.schemaURL = "https://opentelemetry.io/schemas/" + m.To
+	m.reported = map[string]bool{}
+	m.log = log
+	return nil
+}
+
+func parseSemconvVersion(s string) ([3]int, error) {
+	var version [3]int
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return version, fmt.Errorf("%q is not a major.minor.patch version", s)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return version, fmt.Errorf("%q is not a major.minor.patch version", s)
+		}
+		version[i] = n
+	}
+	return version, nil
+}
+
+func compareSemconvVersions(a, b [3]int) int {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] - b[i]
+		}
+	}
+	return 0
+}
+
+func (m *SemconvMigration) processTraces(td ptrace.Traces) {
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		rs.SetSchemaUrl(m.schemaURL)
+		m.migrate(rs.Resource().Attributes())
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			ss := rs.ScopeSpans().At(j)
+			ss.SetSchemaUrl(m.schemaURL)
+			for k := 0; k < ss.Spans().Len(); k++ {
+				span := ss.Spans().At(k)
+				m.migrate(span.Attributes())
+				for l := 0; l < span.Events().Len(); l++ {
+					m.migrate(span.Events().At(l).Attributes())
+				}
+				for l := 0; l < span.Links().Len(); l++ {
+					m.migrate(span.Links().At(l).Attributes())
+				}
+			}
+		}
+	}
+}
+
+// migrate renames the keys of attributes step by step. When both the old and
+// the new key are set the new one is kept.
+func (m *SemconvMigration) migrate(attributes pcommon.Map) {
+	for _, renames := range m.renames {
+		for from, to := range renames {
+			v, ok := attributes.Get(from)
+			if !ok {
+				continue
+			}
+			if _, exists := attributes.Get(to); !exists {
+				v.CopyTo(attributes.PutEmpty(to))
+			}
+			attributes.Remove(from)
+		}
+	}
+	attributes.Range(func(k string, _ pcommon.Value) bool {
+		m.report(k)
+		return true
+	})
+}
+
+// report logs, once, keys in a semantic conventions namespace that the
+// target version doesn't define.
+func (m *SemconvMigration) report(key string) {
+	if m.known[key] || m.reported[key] {
+		return
+	}
+	prefix, _, ok := strings.Cut(key, ".")
+	if !ok || !m.prefixes[prefix] {
+		return
+	}
+	m.reported[key] = true
+	m.log.Debugf("attribute %s is not defined by semantic conventions %s", key, m.To)
+}
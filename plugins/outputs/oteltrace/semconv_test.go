@@ -0,0 +1,76 @@
+package oteltrace_test
+
+import (
+	"testing"
+
+	"github.com/catherinetcai/telegraf-execd-otel/plugins/outputs/oteltrace"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const oldSemconvAttributes = `{
+	"service.name": "checkout",
+	"http.method": "GET",
+	"http.status_code": 200,
+	"http.request.method": "POST",
+	"net.peer.ip": "1.2.3.4",
+	"messaging.kafka.partition": 3,
+	"http.made_up": "x",
+	"tier": "gold"
+}`
+
+func TestOtelTraceSemconvMigration(t *testing.T) {
+	log := &testutil.CaptureLogger{}
+	ot := &oteltrace.OtelTrace{
+		Semconv: &oteltrace.SemconvMigration{From: "1.15.0"},
+		Log:     log,
+	}
+	td := writeTraces(t, ot, attributesSpan("pay", oldSemconvAttributes))
+	require.Equal(t, 1, td.SpanCount())
+	rs := td.ResourceSpans().At(0)
+	assert.Equal(t, "https://opentelemetry.io/schemas/1.25.0", rs.SchemaUrl())
+	span := rs.ScopeSpans().At(0).Spans().At(0)
+	assert.Equal(t, map[string]any{
+		"service.name": "checkout",
+		// Already set with the new key, which wins
+		"http.request.method":                "POST",
+		"http.response.status_code":          int64(200),
+		"network.peer.address":               "1.2.3.4",
+		"messaging.destination.partition.id": int64(3),
+		"http.made_up":                       "x",
+		"tier":                               "gold",
+	}, span.Attributes().AsRaw())
+
+	var unknown []string
+	for _, entry := range log.Messages() {
+		unknown = append(unknown, entry.Text)
+	}
+	assert.Contains(t, unknown, "attribute http.made_up is not defined by semantic conventions 1.25.0")
+	assert.NotContains(t, unknown, "attribute tier is not defined by semantic conventions 1.25.0")
+}
+
+func TestOtelTraceSemconvMigrationTarget(t *testing.T) {
+	ot := &oteltrace.OtelTrace{
+		Semconv: &oteltrace.SemconvMigration{From: "1.16.0", To: "1.21.0"},
+	}
+	td := writeTraces(t, ot, attributesSpan("pay", oldSemconvAttributes))
+	require.Equal(t, 1, td.SpanCount())
+	assert.Equal(t, "https://opentelemetry.io/schemas/1.21.0", td.ResourceSpans().At(0).SchemaUrl())
+	attributes := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Attributes().AsRaw()
+	// Keys renamed before 1.16.0 are only migrated from older versions
+	assert.Equal(t, "1.2.3.4", attributes["net.peer.ip"])
+	assert.NotContains(t, attributes, "net.sock.peer.addr")
+	assert.Equal(t, int64(3), attributes["messaging.kafka.destination.partition"])
+}
+
+func TestOtelTraceSemconvMigrationInit(t *testing.T) {
+	ot := &oteltrace.OtelTrace{Semconv: &oteltrace.SemconvMigration{From: "1.16"}}
+	assert.ErrorContains(t, ot.Init(), `invalid from version: "1.16" is not a major.minor.patch version`)
+
+	ot = &oteltrace.OtelTrace{Semconv: &oteltrace.SemconvMigration{From: "1.16.0", To: "1.30.0"}}
+	assert.ErrorContains(t, ot.Init(), "unsupported to version 1.30.0")
+
+	ot = &oteltrace.OtelTrace{Semconv: &oteltrace.SemconvMigration{From: "1.25.0", To: "1.21.0"}}
+	assert.ErrorContains(t, ot.Init(), "can't migrate from 1.25.0 back to 1.21.0")
+}
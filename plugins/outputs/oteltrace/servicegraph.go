@@ -0,0 +1,368 @@
+package oteltrace
+
+import (
+	"container/list"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/pdatautil"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	semconv "go.opentelemetry.io/collector/semconv/v1.16.0"
+)
+
+// Same names as Tempo's metrics generator, which Grafana's service graph
+// view queries.
+const (
+	defaultServiceGraphInterval = config.Duration(time.Minute)
+	defaultServiceGraphWait     = config.Duration(10 * time.Second)
+	defaultServiceGraphMaxItems = 10000
+
+	serviceGraphRequestTotal       = "traces_service_graph_request_total"
+	serviceGraphRequestFailedTotal = "traces_service_graph_request_failed_total"
+	serviceGraphRequestServer      = "traces_service_graph_request_server_seconds"
+	serviceGraphRequestClient      = "traces_service_graph_request_client_seconds"
+	serviceGraphUnpairedSpansTotal = "traces_service_graph_unpaired_spans_total"
+	serviceGraphDroppedSpansTotal  = "traces_service_graph_dropped_spans_total"
+
+	serviceGraphClient         = "client"
+	serviceGraphServer         = "server"
+	serviceGraphConnectionType = "connection_type"
+
+	connectionTypeMessagingSystem = "messaging_system"
+)
+
+var defaultServiceGraphBuckets = []config.Duration{
+	config.Duration(100 * time.Millisecond),
+	config.Duration(200 * time.Millisecond),
+	config.Duration(400 * time.Millisecond),
+	config.Duration(800 * time.Millisecond),
+	config.Duration(1600 * time.Millisecond),
+	config.Duration(3200 * time.Millisecond),
+	config.Duration(6400 * time.Millisecond),
+	config.Duration(12800 * time.Millisecond),
+}
+
+// ServiceGraphConfig enables service graph metrics generated from the
+// exported spans: requests between services, found by pairing client spans
+// with the server spans that are their children, and producer spans with
+// their consumers.
+type ServiceGraphConfig struct {
+	Interval       config.Duration   `toml:"interval"`
+	Wait           config.Duration   `toml:"wait"`
+	MaxItems       int               `toml:"max_items"`
+	Buckets        []config.Duration `toml:"buckets"`
+	Dimensions     []string          `toml:"dimensions"`
+	ServiceAddress string            `toml:"service_address"`
+}
+
+func (c *ServiceGraphConfig) init() error {
+	if c.Interval <= 0 {
+		c.Interval = defaultServiceGraphInterval
+	}
+	if c.Wait <= 0 {
+		c.Wait = defaultServiceGraphWait
+	}
+	if c.MaxItems <= 0 {
+		c.MaxItems = defaultServiceGraphMaxItems
+	}
+	if len(c.Buckets) == 0 {
+		c.Buckets = defaultServiceGraphBuckets
+	}
+	for i := 1; i < len(c.Buckets); i++ {
+		if c.Buckets[i] <= c.Buckets[i-1] {
+			return fmt.Errorf("service graph buckets must be increasing")
+		}
+	}
+	return nil
+}
+
+// serviceGraph pairs spans into edges, and aggregates complete edges into
+// cumulative series. Edges still missing a side after wait are counted as
+// unpaired.
+type serviceGraph struct {
+	wait       time.Duration
+	maxItems   int
+	bounds     []float64
+	dimensions []string
+	startTime  pcommon.Timestamp
+
+	mu       sync.Mutex
+	edges    map[edgeKey]*list.Element
+	pending  *list.List
+	series   map[[16]byte]*serviceGraphSeries
+	unpaired map[[16]byte]*serviceGraphCount
+	dropped  uint64
+}
+
+// edgeKey is the trace ID and the client span's ID, which is the server
+// span's parent ID.
+type edgeKey struct {
+	traceID pcommon.TraceID
+	spanID  pcommon.SpanID
+}
+
+type edge struct {
+	key            edgeKey
+	created        time.Time
+	connectionType string
+
+	client, server                     string
+	clientLatency, serverLatency       float64
+	clientDimensions, serverDimensions pcommon.Map
+	hasClient, hasServer               bool
+	failed                             bool
+}
+
+type serviceGraphSeries struct {
+	attributes    pcommon.Map
+	total         uint64
+	failed        uint64
+	serverBuckets []uint64
+	serverSum     float64
+	clientBuckets []uint64
+	clientSum     float64
+}
+
+type serviceGraphCount struct {
+	attributes pcommon.Map
+	count      uint64
+}
+
+func newServiceGraph(c *ServiceGraphConfig) *serviceGraph {
+	bounds := make([]float64, 0, len(c.Buckets))
+	for _, bucket := range c.Buckets {
+		bounds = append(bounds, time.Duration(bucket).Seconds())
+	}
+	return &serviceGraph{
+		wait:       time.Duration(c.Wait),
+		maxItems:   c.MaxItems,
+		bounds:     bounds,
+		dimensions: c.Dimensions,
+		startTime:  pcommon.NewTimestampFromTime(time.Now()),
+		edges:      map[edgeKey]*list.Element{},
+		pending:    list.New(),
+		series:     map[[16]byte]*serviceGraphSeries{},
+		unpaired:   map[[16]byte]*serviceGraphCount{},
+	}
+}
+
+func (g *serviceGraph) add(td ptrace.Traces) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := time.Now()
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		serviceName := ""
+		if v, ok := rs.Resource().Attributes().Get(semconv.AttributeServiceName); ok {
+			serviceName = v.AsString()
+		}
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			spans := rs.ScopeSpans().At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				g.addSpan(now, serviceName, rs.Resource(), spans.At(k))
+			}
+		}
+	}
+}
+
+func (g *serviceGraph) addSpan(now time.Time, serviceName string, resource pcommon.Resource, span ptrace.Span) {
+	var key edgeKey
+	var client bool
+	switch span.Kind() {
+	case ptrace.SpanKindClient, ptrace.SpanKindProducer:
+		key = edgeKey{traceID: span.TraceID(), spanID: span.SpanID()}
+		client = true
+	case ptrace.SpanKindServer, ptrace.SpanKindConsumer:
+		if span.ParentSpanID().IsEmpty() {
+			return
+		}
+		key = edgeKey{traceID: span.TraceID(), spanID: span.ParentSpanID()}
+	default:
+		return
+	}
+
+	var e *edge
+	if element, ok := g.edges[key]; ok {
+		e = element.Value.(*edge)
+	} else {
+		if len(g.edges) >= g.maxItems {
+			g.dropped++
+			return
+		}
+		e = &edge{key: key, created: now}
+		g.edges[key] = g.pending.PushBack(e)
+	}
+
+	latency := 0.0
+	if span.EndTimestamp() > span.StartTimestamp() {
+		latency = time.Duration(span.EndTimestamp() - span.StartTimestamp()).Seconds()
+	}
+	dimensions := pcommon.NewMap()
+	for _, dimension := range g.dimensions {
+		if v, ok := span.Attributes().Get(dimension); ok {
+			v.CopyTo(dimensions.PutEmpty(dimension))
+		} else if v, ok := resource.Attributes().Get(dimension); ok {
+			v.CopyTo(dimensions.PutEmpty(dimension))
+		}
+	}
+	if span.Kind() == ptrace.SpanKindProducer || span.Kind() == ptrace.SpanKindConsumer {
+		e.connectionType = connectionTypeMessagingSystem
+	}
+	if span.Status().Code() == ptrace.StatusCodeError {
+		e.failed = true
+	}
+	if client {
+		e.client, e.clientLatency, e.clientDimensions, e.hasClient = serviceName, latency, dimensions, true
+	} else {
+		e.server, e.serverLatency, e.serverDimensions, e.hasServer = serviceName, latency, dimensions, true
+	}
+
+	if e.hasClient && e.hasServer {
+		g.remove(e)
+		g.complete(e)
+	}
+}
+
+func (g *serviceGraph) remove(e *edge) {
+	g.pending.Remove(g.edges[e.key])
+	delete(g.edges, e.key)
+}
+
+// complete adds a paired edge to its series.
+func (g *serviceGraph) complete(e *edge) {
+	attributes := pcommon.NewMap()
+	attributes.PutStr(serviceGraphClient, e.client)
+	attributes.PutStr(serviceGraphServer, e.server)
+	attributes.PutStr(serviceGraphConnectionType, e.connectionType)
+	putDimensions(attributes, "client_", e.clientDimensions)
+	putDimensions(attributes, "server_", e.serverDimensions)
+
+	key := pdatautil.MapHash(attributes)
+	series, ok := g.series[key]
+	if !ok {
+		series = &serviceGraphSeries{
+			attributes:    attributes,
+			serverBuckets: make([]uint64, len(g.bounds)+1),
+			clientBuckets: make([]uint64, len(g.bounds)+1),
+		}
+		g.series[key] = series
+	}
+	series.total++
+	if e.failed {
+		series.failed++
+	}
+	series.serverSum += e.serverLatency
+	series.serverBuckets[sort.SearchFloat64s(g.bounds, e.serverLatency)]++
+	series.clientSum += e.clientLatency
+	series.clientBuckets[sort.SearchFloat64s(g.bounds, e.clientLatency)]++
+}
+
+// expire counts the edges that waited too long for their other side as
+// unpaired, labelled with the side that was seen.
+func (g *serviceGraph) expire(now time.Time) {
+	for element := g.pending.Front(); element != nil; element = g.pending.Front() {
+		e := element.Value.(*edge)
+		if now.Sub(e.created) < g.wait {
+			return
+		}
+		g.remove(e)
+
+		attributes := pcommon.NewMap()
+		if e.hasClient {
+			attributes.PutStr(serviceGraphClient, e.client)
+		} else {
+			attributes.PutStr(serviceGraphServer, e.server)
+		}
+		key := pdatautil.MapHash(attributes)
+		unpaired, ok := g.unpaired[key]
+		if !ok {
+			unpaired = &serviceGraphCount{attributes: attributes}
+			g.unpaired[key] = unpaired
+		}
+		unpaired.count++
+	}
+}
+
+func putDimensions(attributes pcommon.Map, prefix string, dimensions pcommon.Map) {
+	dimensions.Range(func(k string, v pcommon.Value) bool {
+		v.CopyTo(attributes.PutEmpty(prefix + k))
+		return true
+	})
+}
+
+// metrics expires the edges that are due and returns the cumulative state of
+// every series, or false when nothing has been counted yet.
+func (g *serviceGraph) metrics(now time.Time) (pmetric.Metrics, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.expire(now)
+	md := pmetric.NewMetrics()
+	if len(g.series) == 0 && len(g.unpaired) == 0 && g.dropped == 0 {
+		return md, false
+	}
+
+	scope := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty()
+	scope.Scope().SetName("servicegraph")
+	timestamp := pcommon.NewTimestampFromTime(now)
+	sum := func(name string) pmetric.Sum {
+		metric := scope.Metrics().AppendEmpty()
+		metric.SetName(name)
+		sum := metric.SetEmptySum()
+		sum.SetIsMonotonic(true)
+		sum.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+		return sum
+	}
+	count := func(sum pmetric.Sum, attributes pcommon.Map, value uint64) {
+		dp := sum.DataPoints().AppendEmpty()
+		attributes.CopyTo(dp.Attributes())
+		dp.SetStartTimestamp(g.startTime)
+		dp.SetTimestamp(timestamp)
+		dp.SetIntValue(int64(value))
+	}
+	histogram := func(name string) pmetric.Histogram {
+		metric := scope.Metrics().AppendEmpty()
+		metric.SetName(name)
+		metric.SetUnit("s")
+		histogram := metric.SetEmptyHistogram()
+		histogram.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+		return histogram
+	}
+	observe := func(histogram pmetric.Histogram, series *serviceGraphSeries, sum float64, buckets []uint64) {
+		dp := histogram.DataPoints().AppendEmpty()
+		series.attributes.CopyTo(dp.Attributes())
+		dp.SetStartTimestamp(g.startTime)
+		dp.SetTimestamp(timestamp)
+		dp.SetCount(series.total)
+		dp.SetSum(sum)
+		dp.ExplicitBounds().FromRaw(g.bounds)
+		dp.BucketCounts().FromRaw(buckets)
+	}
+
+	if len(g.series) > 0 {
+		total := sum(serviceGraphRequestTotal)
+		failed := sum(serviceGraphRequestFailedTotal)
+		server := histogram(serviceGraphRequestServer)
+		client := histogram(serviceGraphRequestClient)
+		for _, series := range g.series {
+			count(total, series.attributes, series.total)
+			count(failed, series.attributes, series.failed)
+			observe(server, series, series.serverSum, series.serverBuckets)
+			observe(client, series, series.clientSum, series.clientBuckets)
+		}
+	}
+	if len(g.unpaired) > 0 {
+		unpaired := sum(serviceGraphUnpairedSpansTotal)
+		for _, series := range g.unpaired {
+			count(unpaired, series.attributes, series.count)
+		}
+	}
+	if g.dropped > 0 {
+		count(sum(serviceGraphDroppedSpansTotal), pcommon.NewMap(), g.dropped)
+	}
+	return md, true
+}
@@ -0,0 +1,124 @@
+package oteltrace_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/catherinetcai/telegraf-execd-otel/plugins/outputs/oteltrace"
+	influxcommon "github.com/influxdata/influxdb-observability/common"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	semconv "go.opentelemetry.io/collector/semconv/v1.16.0"
+	"google.golang.org/grpc"
+)
+
+// graphSpan returns a span of service, a child of parentSpanID unless it's 0.
+func graphSpan(spanID, parentSpanID int, kind ptrace.SpanKind, service string, duration time.Duration, statusCode string) telegraf.Metric {
+	m := generateTraceAsMetric()
+	m.AddTag(influxcommon.AttributeSpanID, fmt.Sprintf("%016x", spanID))
+	if parentSpanID != 0 {
+		m.AddField(influxcommon.AttributeParentSpanID, fmt.Sprintf("%016x", parentSpanID))
+	}
+	m.AddField(influxcommon.AttributeSpanKind, kind.String())
+	m.AddField(influxcommon.AttributeAttributes, fmt.Sprintf(`{"service.name":%q}`, service))
+	m.AddField(influxcommon.AttributeEndTimeUnixNano, m.Time().Add(duration).UnixNano())
+	m.AddField(semconv.OtelStatusCode, statusCode)
+	return m
+}
+
+func TestOtelTraceServiceGraph(t *testing.T) {
+	metricsServer := &recordingMetricsServer{}
+	address := startServer(t, func(s *grpc.Server) {
+		ptraceotlp.RegisterGRPCServer(s, &recordingTracesServer{})
+		pmetricotlp.RegisterGRPCServer(s, metricsServer)
+	})
+	ot := &oteltrace.OtelTrace{
+		ServiceAddress: address,
+		ServiceGraph: &oteltrace.ServiceGraphConfig{
+			Interval: config.Duration(time.Hour),
+			// Anything unpaired at close has expired
+			Wait:    config.Duration(time.Nanosecond),
+			Buckets: []config.Duration{config.Duration(100 * time.Millisecond), config.Duration(time.Second)},
+		},
+		Log: &testutil.Logger{},
+	}
+	require.NoError(t, ot.Init())
+	require.NoError(t, ot.Connect())
+
+	require.NoError(t, ot.Write([]telegraf.Metric{
+		graphSpan(2, 1, ptrace.SpanKindClient, "frontend", 60*time.Millisecond, "STATUS_CODE_OK"),
+		graphSpan(3, 2, ptrace.SpanKindServer, "checkout", 50*time.Millisecond, "STATUS_CODE_OK"),
+		graphSpan(4, 1, ptrace.SpanKindClient, "frontend", 600*time.Millisecond, "STATUS_CODE_OK"),
+		graphSpan(6, 1, ptrace.SpanKindClient, "frontend", 10*time.Millisecond, "STATUS_CODE_OK"),
+		graphSpan(7, 3, ptrace.SpanKindProducer, "checkout", time.Millisecond, "STATUS_CODE_OK"),
+		graphSpan(8, 7, ptrace.SpanKindConsumer, "billing", 2*time.Second, "STATUS_CODE_OK"),
+	}))
+	// The other side of an edge can come in a later write
+	require.NoError(t, ot.Write([]telegraf.Metric{
+		graphSpan(5, 4, ptrace.SpanKindServer, "checkout", 500*time.Millisecond, "STATUS_CODE_ERROR"),
+	}))
+	require.NoError(t, ot.Close())
+
+	require.Len(t, metricsServer.requests, 1)
+	metrics := metricsServer.requests[0].Metrics().ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	byName := map[string]pmetric.Metric{}
+	for i := 0; i < metrics.Len(); i++ {
+		byName[metrics.At(i).Name()] = metrics.At(i)
+	}
+
+	edge := func(dp pmetric.NumberDataPoint) string {
+		attributes := dp.Attributes().AsRaw()
+		return fmt.Sprintf("%v->%v/%v", attributes["client"], attributes["server"], attributes["connection_type"])
+	}
+	counts := func(name string) map[string]int64 {
+		require.Contains(t, byName, name)
+		counts := map[string]int64{}
+		dps := byName[name].Sum().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			counts[edge(dps.At(i))] = dps.At(i).IntValue()
+		}
+		return counts
+	}
+	assert.Equal(t, map[string]int64{
+		"frontend->checkout/":                2,
+		"checkout->billing/messaging_system": 1,
+	}, counts("traces_service_graph_request_total"))
+	assert.Equal(t, map[string]int64{
+		"frontend->checkout/":                1,
+		"checkout->billing/messaging_system": 0,
+	}, counts("traces_service_graph_request_failed_total"))
+	assert.Equal(t, map[string]int64{"frontend-><nil>/<nil>": 1}, counts("traces_service_graph_unpaired_spans_total"))
+
+	require.Contains(t, byName, "traces_service_graph_request_server_seconds")
+	server := byName["traces_service_graph_request_server_seconds"].Histogram().DataPoints()
+	require.Equal(t, 2, server.Len())
+	for i := 0; i < server.Len(); i++ {
+		dp := server.At(i)
+		assert.Equal(t, []float64{0.1, 1}, dp.ExplicitBounds().AsRaw())
+		if client, _ := dp.Attributes().Get("client"); client.AsString() == "frontend" {
+			assert.Equal(t, []uint64{1, 1, 0}, dp.BucketCounts().AsRaw())
+			assert.InDelta(t, 0.55, dp.Sum(), 0.001)
+		} else {
+			assert.Equal(t, []uint64{0, 0, 1}, dp.BucketCounts().AsRaw())
+		}
+	}
+	require.Contains(t, byName, "traces_service_graph_request_client_seconds")
+	client := byName["traces_service_graph_request_client_seconds"].Histogram().DataPoints()
+	require.Equal(t, 2, client.Len())
+}
+
+func TestOtelTraceServiceGraphInit(t *testing.T) {
+	ot := &oteltrace.OtelTrace{
+		ServiceGraph: &oteltrace.ServiceGraphConfig{},
+		Exporters:    []*oteltrace.ExporterConfig{{Name: "debug", Type: "debug"}},
+	}
+	assert.ErrorContains(t, ot.Init(), "service graph needs a service_address or at least one otlp exporter")
+}
@@ -1,15 +1,15 @@
 package oteltrace
 
 import (
-	"encoding/json"
 	"fmt"
+	"math"
+	"slices"
 	"time"
 
 	influxcommon "github.com/influxdata/influxdb-observability/common"
 	"github.com/influxdata/telegraf"
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/ptrace"
-	semconv "go.opentelemetry.io/collector/semconv/v1.16.0"
 	"go.opentelemetry.io/otel/trace"
 	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
 )
@@ -17,10 +17,12 @@ import (
 func (o *OtelTrace) handleSpan(metric telegraf.Metric) (ptrace.Span, error) {
 	o.Log.Debugf("handling span: %s", metric.Name())
 	span := ptrace.NewSpan()
+	// otel2influx uses the span's start time as the point's timestamp
+	span.SetStartTimestamp(pcommon.NewTimestampFromTime(metric.Time()))
 
 	tags := metric.TagList()
 	for _, tag := range tags {
-		if tag.Key == influxcommon.AttributeTraceID {
+		if tag.Key == o.Schema.TraceID {
 			o.Log.Debugf("span trace ID string: %s", tag.Value)
 			// TODO: This is where the conversion goes wrong
 			decodedTraceID, err := trace.TraceIDFromHex(tag.Value)
@@ -32,7 +34,7 @@ func (o *OtelTrace) handleSpan(metric telegraf.Metric) (ptrace.Span, error) {
 			traceID := pcommon.TraceID(decodedTraceID)
 			span.SetTraceID(traceID)
 		}
-		if tag.Key == influxcommon.AttributeSpanID {
+		if tag.Key == o.Schema.SpanID {
 			o.Log.Debugf("span span ID string: %s", tag.Value)
 			decodedSpanID, err := trace.SpanIDFromHex(tag.Value)
 			if err != nil {
@@ -43,7 +45,10 @@ func (o *OtelTrace) handleSpan(metric telegraf.Metric) (ptrace.Span, error) {
 			spanID := pcommon.SpanID(decodedSpanID)
 			span.SetSpanID(spanID)
 		}
-		// TODO: There are other things that can be configured to be span dimensions
+		// The name is a tag when it's one of otel2influx's span_dimensions
+		if tag.Key == o.Schema.Name {
+			span.SetName(tag.Value)
+		}
 	}
 
 	fields := metric.FieldList()
@@ -58,7 +63,7 @@ func (o *OtelTrace) handleSpan(metric telegraf.Metric) (ptrace.Span, error) {
 			}
 			span.TraceState().FromRaw(traceState)
 		}
-		if field.Key == influxcommon.AttributeParentSpanID {
+		if field.Key == o.Schema.ParentSpanID {
 			parentSpanIDStr, ok := field.Value.(string)
 			if !ok {
 				err := fmt.Errorf("parent span ID should be of type string but isn't")
@@ -76,7 +81,7 @@ func (o *OtelTrace) handleSpan(metric telegraf.Metric) (ptrace.Span, error) {
 			pSid := pcommon.SpanID(parentSpanID)
 			span.SetParentSpanID(pSid)
 		}
-		if field.Key == influxcommon.AttributeSpanName {
+		if field.Key == o.Schema.Name {
 			spanNameRaw := field.Value
 			spanName, ok := spanNameRaw.(string)
 			if !ok {
@@ -84,7 +89,7 @@ func (o *OtelTrace) handleSpan(metric telegraf.Metric) (ptrace.Span, error) {
 			}
 			span.SetName(spanName)
 		}
-		if field.Key == influxcommon.AttributeSpanKind {
+		if field.Key == o.Schema.Kind {
 			o.Log.Debugf("span kind: %+v", field.Value)
 			spanKindRaw := field.Value
 			spanKindStr, ok := field.Value.(string)
@@ -94,7 +99,7 @@ func (o *OtelTrace) handleSpan(metric telegraf.Metric) (ptrace.Span, error) {
 			sk := SpanKindFromString(spanKindStr)
 			span.SetKind(ptrace.SpanKind(int32(sk)))
 		}
-		if field.Key == influxcommon.AttributeEndTimeUnixNano {
+		if field.Key == o.Schema.EndTime {
 			endTimeRaw := field.Value
 			endTime, ok := field.Value.(int64)
 			if !ok {
@@ -103,7 +108,7 @@ func (o *OtelTrace) handleSpan(metric telegraf.Metric) (ptrace.Span, error) {
 			et := time.Unix(0, endTime)
 			span.SetEndTimestamp(pcommon.NewTimestampFromTime(et))
 		}
-		if field.Key == semconv.OtelStatusCode {
+		if field.Key == o.Schema.StatusCode {
 			statusCodeRaw := field.Value
 			statusCodeStr, ok := statusCodeRaw.(string)
 			if !ok {
@@ -112,7 +117,7 @@ func (o *OtelTrace) handleSpan(metric telegraf.Metric) (ptrace.Span, error) {
 			sc := ptrace.StatusCode(tracepb.Status_StatusCode(tracepb.Status_StatusCode_value[statusCodeStr]))
 			span.Status().SetCode(sc)
 		}
-		if field.Key == semconv.OtelStatusDescription {
+		if field.Key == o.Schema.StatusMessage {
 			statusMessageRaw := field.Value
 			statusMessage, ok := statusMessageRaw.(string)
 			if !ok {
@@ -122,12 +127,37 @@ func (o *OtelTrace) handleSpan(metric telegraf.Metric) (ptrace.Span, error) {
 		}
 		if field.Key == influxcommon.AttributeDroppedAttributesCount {
 			droppedAttrCountRaw := field.Value
-			droppedAttrCount, ok := droppedAttrCountRaw.(uint64)
+			droppedAttrCount, ok := uint32Field(droppedAttrCountRaw)
 			if !ok {
 				return span, fmt.Errorf("invalid type for dropped attributes count %v", droppedAttrCountRaw)
 			}
 			// influx wants uint64, traces want uint32 - go figure
-			span.SetDroppedAttributesCount(uint32(droppedAttrCount))
+			span.SetDroppedAttributesCount(droppedAttrCount)
+		}
+		if field.Key == influxcommon.AttributeDroppedEventsCount {
+			droppedEventsCountRaw := field.Value
+			droppedEventsCount, ok := uint32Field(droppedEventsCountRaw)
+			if !ok {
+				return span, fmt.Errorf("invalid type for dropped events count %v", droppedEventsCountRaw)
+			}
+			span.SetDroppedEventsCount(droppedEventsCount)
+		}
+		if field.Key == influxcommon.AttributeDroppedLinksCount {
+			droppedLinksCountRaw := field.Value
+			droppedLinksCount, ok := uint32Field(droppedLinksCountRaw)
+			if !ok {
+				return span, fmt.Errorf("invalid type for dropped links count %v", droppedLinksCountRaw)
+			}
+			span.SetDroppedLinksCount(droppedLinksCount)
+		}
+		// W3C trace flags, e.g. 1 when the span was sampled
+		if field.Key == influxcommon.AttributeFlags {
+			flagsRaw := field.Value
+			flags, ok := uint32Field(flagsRaw)
+			if !ok {
+				return span, fmt.Errorf("invalid type for flags %v", flagsRaw)
+			}
+			span.SetFlags(flags)
 		}
 		if field.Key == influxcommon.AttributeAttributes {
 			attributesRaw := field.Value
@@ -135,12 +165,49 @@ func (o *OtelTrace) handleSpan(metric telegraf.Metric) (ptrace.Span, error) {
 			if !ok {
 				return span, fmt.Errorf("invalid type for attributes %v", attributesRaw)
 			}
-			attributesField := make(map[string]any)
-			if err := json.Unmarshal([]byte(attributesRawStr), &attributesField); err != nil {
+			if err := o.decoder.decodeInto(attributesRawStr, span.Attributes()); err != nil {
 				return span, fmt.Errorf("failed to unmarshal attributes to map %w", err)
 			}
-			span.Attributes().FromRaw(attributesField)
+		}
+	}
+
+	// Attributes promoted to tags by otel2influx's span_dimensions, or
+	// columns added by Telegraf processors, would otherwise be lost. The
+	// attributes field has the exact types, so it wins over them.
+	if !o.IgnoreUnknownColumns {
+		attributes := span.Attributes()
+		for _, tag := range tags {
+			if _, ok := attributes.Get(tag.Key); !ok && o.unknownColumn(tag.Key) {
+				o.decoder.putTag(attributes, tag.Key, tag.Value)
+			}
+		}
+		for _, field := range fields {
+			if _, ok := attributes.Get(field.Key); !ok && o.unknownColumn(field.Key) {
+				o.decoder.put(attributes, field.Key, field.Value)
+			}
 		}
 	}
 	return span, nil
 }
+
+// uint32Field reads a count or flags field of a span, link or log record. otel2influx writes them unsigned,
+// but Telegraf's influx serializer sends them as signed integers unless
+// influx_uint_support is set.
+func uint32Field(value interface{}) (uint32, bool) {
+	switch v := value.(type) {
+	case uint64:
+		if v <= math.MaxUint32 {
+			return uint32(v), true
+		}
+	case int64:
+		if v >= 0 && v <= math.MaxUint32 {
+			return uint32(v), true
+		}
+	}
+	return 0, false
+}
+
+// unknownColumn reports whether key is neither a span column nor excluded.
+func (o *OtelTrace) unknownColumn(key string) bool {
+	return !o.Schema.columns[key] && !slices.Contains(o.UnknownColumnsExclude, key)
+}
@@ -0,0 +1,207 @@
+package oteltrace_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/catherinetcai/telegraf-execd-otel/plugins/outputs/oteltrace"
+	influxcommon "github.com/influxdata/influxdb-observability/common"
+	"github.com/influxdata/influxdb-observability/otel2influx"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	parsersinflux "github.com/influxdata/telegraf/plugins/parsers/influx"
+	serializersinflux "github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// metricsWriter collects the points otel2influx writes as Telegraf metrics,
+// as the execd output would receive them.
+type metricsWriter struct {
+	metrics []telegraf.Metric
+}
+
+func (w *metricsWriter) NewBatch() otel2influx.InfluxWriterBatch {
+	return w
+}
+
+func (w *metricsWriter) EnqueuePoint(_ context.Context, measurement string, tags map[string]string, fields map[string]interface{}, ts time.Time, _ influxcommon.InfluxMetricValueType) error {
+	w.metrics = append(w.metrics, metric.New(measurement, tags, fields, ts))
+	return nil
+}
+
+func (w *metricsWriter) WriteBatch(context.Context) error {
+	return nil
+}
+
+// otel2influxMetrics converts td the way otel2influx writes it to InfluxDB,
+// with the attributes in dimensions written as tags.
+func otel2influxMetrics(t *testing.T, td ptrace.Traces, dimensions ...string) []telegraf.Metric {
+	writer := &metricsWriter{}
+	config := otel2influx.DefaultOtelTracesToLineProtocolConfig()
+	config.Writer = writer
+	config.SpanDimensions = dimensions
+	converter, err := otel2influx.NewOtelTracesToLineProtocol(config)
+	require.NoError(t, err)
+	require.NoError(t, converter.WriteTraces(context.Background(), td))
+	return writer.metrics
+}
+
+func TestOtelTraceDroppedCountsRoundTrip(t *testing.T) {
+	td := ptrace.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("service.name", "checkout")
+	span := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetTraceID(pcommon.TraceID{1})
+	span.SetSpanID(pcommon.SpanID{2})
+	span.SetName("pay")
+	start := time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
+	span.SetStartTimestamp(pcommon.NewTimestampFromTime(start))
+	span.SetEndTimestamp(pcommon.NewTimestampFromTime(start.Add(time.Second)))
+	span.SetDroppedAttributesCount(1)
+	span.SetDroppedEventsCount(3)
+	span.SetDroppedLinksCount(2)
+
+	exported := writeTraces(t, &oteltrace.OtelTrace{}, otel2influxMetrics(t, td)...)
+	require.Equal(t, 1, exported.SpanCount())
+	got := exported.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0)
+	assert.Equal(t, "pay", got.Name())
+	assert.Equal(t, uint32(1), got.DroppedAttributesCount())
+	assert.Equal(t, uint32(3), got.DroppedEventsCount())
+	assert.Equal(t, uint32(2), got.DroppedLinksCount())
+}
+
+// lineProtocol sends metrics through Telegraf's default influx serializer and
+// parser, as the execd output and its process do.
+func lineProtocol(t *testing.T, metrics []telegraf.Metric) []telegraf.Metric {
+	serializer := &serializersinflux.Serializer{}
+	require.NoError(t, serializer.Init())
+	parser := &parsersinflux.Parser{}
+	require.NoError(t, parser.Init())
+	var parsed []telegraf.Metric
+	for _, m := range metrics {
+		line, err := serializer.Serialize(m)
+		require.NoError(t, err)
+		ms, err := parser.Parse(line)
+		require.NoError(t, err)
+		parsed = append(parsed, ms...)
+	}
+	return parsed
+}
+
+func TestOtelTraceDroppedCountsLineProtocol(t *testing.T) {
+	td := ptrace.NewTraces()
+	span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetTraceID(pcommon.TraceID{1})
+	span.SetSpanID(pcommon.SpanID{2})
+	span.SetStartTimestamp(pcommon.NewTimestampFromTime(time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)))
+	span.SetDroppedAttributesCount(1)
+	span.SetDroppedEventsCount(3)
+	span.SetDroppedLinksCount(2)
+	link := span.Links().AppendEmpty()
+	link.SetTraceID(pcommon.TraceID{3})
+	link.SetSpanID(pcommon.SpanID{4})
+	link.SetDroppedAttributesCount(4)
+	event := span.Events().AppendEmpty()
+	event.SetName("retry")
+	event.SetTimestamp(span.StartTimestamp())
+	event.SetDroppedAttributesCount(5)
+
+	metrics := lineProtocol(t, otel2influxMetrics(t, td))
+	require.Len(t, metrics, 3)
+	// Without influx_uint_support the counts arrive signed
+	for _, m := range metrics {
+		dropped, _ := m.GetField(influxcommon.AttributeDroppedAttributesCount)
+		require.IsType(t, int64(0), dropped, m.Name())
+		if m.Name() == influxcommon.MeasurementSpans {
+			m.AddField(influxcommon.AttributeFlags, int64(1))
+		}
+	}
+
+	exported := writeTraces(t, &oteltrace.OtelTrace{LogSpanEvents: true}, metrics...)
+	require.Equal(t, 1, exported.SpanCount())
+	got := exported.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0)
+	assert.Equal(t, uint32(1), got.DroppedAttributesCount())
+	assert.Equal(t, uint32(3), got.DroppedEventsCount())
+	assert.Equal(t, uint32(2), got.DroppedLinksCount())
+	assert.Equal(t, uint32(1), got.Flags())
+	require.Equal(t, 1, got.Links().Len())
+	assert.Equal(t, uint32(4), got.Links().At(0).DroppedAttributesCount())
+	require.Equal(t, 1, got.Events().Len())
+	assert.Equal(t, uint32(5), got.Events().At(0).DroppedAttributesCount())
+
+	m := generateTraceAsMetric()
+	m.AddField(influxcommon.AttributeDroppedEventsCount, int64(-1))
+	ot := &oteltrace.OtelTrace{Log: &testutil.Logger{}}
+	require.NoError(t, ot.Init())
+	assert.ErrorContains(t, ot.Write([]telegraf.Metric{m}), "invalid type for dropped events count -1")
+}
+
+func TestOtelTraceSpanFlags(t *testing.T) {
+	// otel2influx doesn't write span flags yet, but the field is read like
+	// the log records' one
+	m := generateTraceAsMetric()
+	m.AddField(influxcommon.AttributeFlags, uint64(1))
+	td := writeTraces(t, &oteltrace.OtelTrace{}, m)
+	require.Equal(t, 1, td.SpanCount())
+	assert.Equal(t, uint32(1), td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Flags())
+
+	m = generateTraceAsMetric()
+	m.AddField(influxcommon.AttributeDroppedLinksCount, "2")
+	ot := &oteltrace.OtelTrace{Log: &testutil.Logger{}}
+	require.NoError(t, ot.Init())
+	assert.ErrorContains(t, ot.Write([]telegraf.Metric{m}), "invalid type for dropped links count 2")
+}
+
+func TestOtelTraceUnknownColumns(t *testing.T) {
+	td := ptrace.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("service.name", "checkout")
+	span := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetTraceID(pcommon.TraceID{1})
+	span.SetSpanID(pcommon.SpanID{2})
+	span.SetName("pay")
+	start := time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
+	span.SetStartTimestamp(pcommon.NewTimestampFromTime(start))
+	span.SetEndTimestamp(pcommon.NewTimestampFromTime(start.Add(time.Second)))
+	span.Attributes().PutStr("http.method", "POST")
+	span.Attributes().PutInt("http.status_code", 201)
+	span.Attributes().PutStr("app.version", "1.10")
+
+	metrics := otel2influxMetrics(t, td, "service.name", "span.name", "http.method", "http.status_code", "app.version")
+	require.Len(t, metrics, 1)
+	// Added by Telegraf processors
+	metrics[0].AddTag("host", "telegraf-1")
+	metrics[0].AddField("region", "eu-west-1")
+	metrics[0].AddField("sampled", true)
+
+	// Telegraf's host tag is excluded by default
+	exported := writeTraces(t, &oteltrace.OtelTrace{}, metrics...)
+	require.Equal(t, 1, exported.SpanCount())
+	got := exported.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0)
+	assert.Equal(t, "pay", got.Name())
+	assert.Equal(t, map[string]any{
+		"service.name":     "checkout",
+		"http.method":      "POST",
+		"http.status_code": int64(201),
+		"app.version":      "1.10",
+		"region":           "eu-west-1",
+		"sampled":          true,
+	}, got.Attributes().AsRaw())
+	assert.Equal(t, "checkout", exported.ResourceSpans().At(0).Resource().Attributes().AsRaw()["service.name"])
+
+	// A configured list replaces the default
+	exported = writeTraces(t, &oteltrace.OtelTrace{UnknownColumnsExclude: []string{"region"}}, metrics...)
+	require.Equal(t, 1, exported.SpanCount())
+	got = exported.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0)
+	assert.Equal(t, "telegraf-1", got.Attributes().AsRaw()["host"])
+	assert.NotContains(t, got.Attributes().AsRaw(), "region")
+
+	exported = writeTraces(t, &oteltrace.OtelTrace{IgnoreUnknownColumns: true}, metrics...)
+	require.Equal(t, 1, exported.SpanCount())
+	assert.Empty(t, exported.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Attributes().AsRaw())
+}
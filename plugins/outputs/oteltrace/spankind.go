@@ -1,8 +1,10 @@
 package oteltrace
 
 import (
+	"fmt"
 	"strings"
 
+	"go.opentelemetry.io/collector/pdata/ptrace"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -22,3 +24,42 @@ func SpanKindFromString(sk string) trace.SpanKind {
 		return trace.SpanKindInternal
 	}
 }
+
+// spanKindFromConfig accepts span kinds with or without the SPAN_KIND_
+// prefix, in any case. Unlike SpanKindFromString, unknown kinds are an error
+// rather than internal, so that typos in the config don't go unnoticed.
+func spanKindFromConfig(kind string) (ptrace.SpanKind, error) {
+	switch strings.TrimPrefix(strings.ToUpper(kind), "SPAN_KIND_") {
+	case "UNSPECIFIED":
+		return ptrace.SpanKindUnspecified, nil
+	case "INTERNAL":
+		return ptrace.SpanKindInternal, nil
+	case "SERVER":
+		return ptrace.SpanKindServer, nil
+	case "CLIENT":
+		return ptrace.SpanKindClient, nil
+	case "PRODUCER":
+		return ptrace.SpanKindProducer, nil
+	case "CONSUMER":
+		return ptrace.SpanKindConsumer, nil
+	default:
+		return ptrace.SpanKindUnspecified, fmt.Errorf("invalid span kind %q", kind)
+	}
+}
+
+// zipkinSpanKind maps a span kind onto Zipkin's kind field. Zipkin has no
+// internal kind, so those spans leave it unset.
+func zipkinSpanKind(sk trace.SpanKind) string {
+	switch sk {
+	case trace.SpanKindServer:
+		return "SERVER"
+	case trace.SpanKindClient:
+		return "CLIENT"
+	case trace.SpanKindProducer:
+		return "PRODUCER"
+	case trace.SpanKindConsumer:
+		return "CONSUMER"
+	default:
+		return ""
+	}
+}
@@ -1,35 +1,50 @@
 package oteltrace
 
 import (
-	"encoding/json"
 	"fmt"
 
 	influxcommon "github.com/influxdata/influxdb-observability/common"
 	"github.com/influxdata/telegraf"
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// TODO: Returns the spanlink with the linked trace ID and span ID attached, but
-// this still has to be appended to the pre-existing span's links
-func (o *OtelTrace) handleSpanLink(metric telegraf.Metric) (ptrace.SpanLink, error) {
+// linkRecord is a converted span link along with the IDs of the span it
+// belongs to, which otel2influx writes as its trace and span ID tags.
+type linkRecord struct {
+	ptrace.SpanLink
+	traceID pcommon.TraceID
+	spanID  pcommon.SpanID
+}
+
+func (o *OtelTrace) handleSpanLink(metric telegraf.Metric) (linkRecord, error) {
 	o.Log.Debugf("handling span link: %s", metric.Name())
-	spanLink := ptrace.NewSpanLink()
+	link := linkRecord{SpanLink: ptrace.NewSpanLink()}
+	spanLink := link.SpanLink
 	tags := metric.TagList()
 
 	for _, tag := range tags {
+		var err error
+		switch tag.Key {
+		case o.Schema.TraceID:
+			link.traceID, err = traceIDFromHex(tag.Value)
+		case o.Schema.SpanID:
+			link.spanID, err = spanIDFromHex(tag.Value)
 		// https://github.com/influxdata/influxdb-observability/blob/main/otel2influx/traces.go#L267
-		if tag.Key == influxcommon.AttributeLinkedTraceID {
+		case influxcommon.AttributeLinkedTraceID:
 			o.Log.Debugf("spanlink linked trace ID: %s", tag.Value)
-			pLinkedTraceID := pcommon.TraceID(([]byte(tag.Value)))
-			spanLink.SetTraceID(pLinkedTraceID)
-			// linkedTraceID = pLinkedTraceID.String()
-		}
-		if tag.Key == influxcommon.AttributeLinkedSpanID {
+			var linkedTraceID pcommon.TraceID
+			linkedTraceID, err = traceIDFromHex(tag.Value)
+			spanLink.SetTraceID(linkedTraceID)
+		case influxcommon.AttributeLinkedSpanID:
 			o.Log.Debugf("spanlink linked span ID: %s", tag.Value)
-			pLinkedSpanID := pcommon.SpanID([]byte(tag.Value))
-			spanLink.SetSpanID(pLinkedSpanID)
-			// linkedSpanID = pLinkedSpanID.String()
+			var linkedSpanID pcommon.SpanID
+			linkedSpanID, err = spanIDFromHex(tag.Value)
+			spanLink.SetSpanID(linkedSpanID)
+		}
+		if err != nil {
+			return link, fmt.Errorf("span link %s: %w", tag.Key, err)
 		}
 	}
 
@@ -39,7 +54,7 @@ func (o *OtelTrace) handleSpanLink(metric telegraf.Metric) (ptrace.SpanLink, err
 			traceStateRaw := field.Value
 			traceState, ok := traceStateRaw.(string)
 			if !ok {
-				return spanLink, fmt.Errorf("invalid type for span trace_state %v", traceStateRaw)
+				return link, fmt.Errorf("invalid type for span trace_state %v", traceStateRaw)
 			}
 			spanLink.TraceState().FromRaw(traceState)
 		}
@@ -47,24 +62,37 @@ func (o *OtelTrace) handleSpanLink(metric telegraf.Metric) (ptrace.SpanLink, err
 			attributesRaw := field.Value
 			attributesRawStr, ok := attributesRaw.(string)
 			if !ok {
-				return spanLink, fmt.Errorf("invalid type for attributes %v", attributesRaw)
+				return link, fmt.Errorf("invalid type for attributes %v", attributesRaw)
 			}
-			attributesField := make(map[string]any)
-			if err := json.Unmarshal([]byte(attributesRawStr), &attributesField); err != nil {
-				return spanLink, fmt.Errorf("failed to unmarshal attributes to map %w", err)
+			if err := o.decoder.decodeInto(attributesRawStr, spanLink.Attributes()); err != nil {
+				return link, fmt.Errorf("failed to unmarshal attributes to map %w", err)
 			}
-			spanLink.Attributes().FromRaw(attributesField)
 		}
 		if field.Key == influxcommon.AttributeDroppedAttributesCount {
 			droppedAttrCountRaw := field.Value
-			droppedAttrCount, ok := droppedAttrCountRaw.(uint64)
+			droppedAttrCount, ok := uint32Field(droppedAttrCountRaw)
 			if !ok {
-				return spanLink, fmt.Errorf("invalid type for dropped attributes count %v", droppedAttrCountRaw)
+				return link, fmt.Errorf("invalid type for dropped attributes count %v", droppedAttrCountRaw)
 			}
-			// ptrace takes this as uint32, influx takes it as uint64
-			spanLink.SetDroppedAttributesCount(uint32(droppedAttrCount))
+			spanLink.SetDroppedAttributesCount(droppedAttrCount)
 		}
 	}
 
-	return spanLink, nil
+	return link, nil
+}
+
+func traceIDFromHex(s string) (pcommon.TraceID, error) {
+	traceID, err := trace.TraceIDFromHex(s)
+	if err != nil {
+		return pcommon.TraceID{}, fmt.Errorf("unable to convert trace ID hex string %s: %w", s, err)
+	}
+	return pcommon.TraceID(traceID), nil
+}
+
+func spanIDFromHex(s string) (pcommon.SpanID, error) {
+	spanID, err := trace.SpanIDFromHex(s)
+	if err != nil {
+		return pcommon.SpanID{}, fmt.Errorf("unable to convert span ID hex string %s: %w", s, err)
+	}
+	return pcommon.SpanID(spanID), nil
 }
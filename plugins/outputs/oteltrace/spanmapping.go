@@ -0,0 +1,245 @@
+package oteltrace
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	semconv "go.opentelemetry.io/collector/semconv/v1.16.0"
+)
+
+const defaultEndTimeFormat = "unix_ns"
+
+var (
+	defaultErrorValues = []string{"error", "failed", "failure"}
+	defaultOKValues    = []string{"ok", "success", "succeeded"}
+)
+
+// SpanMapping turns the points of a measurement that wasn't written by
+// otel2influx into spans. Every key names a tag or field of the point, the
+// point's time is the span's start time.
+type SpanMapping struct {
+	Measurement   string          `toml:"measurement"`
+	TraceID       string          `toml:"trace_id"`
+	TraceIDTags   []string        `toml:"trace_id_tags"`
+	SpanID        string          `toml:"span_id"`
+	SpanIDTags    []string        `toml:"span_id_tags"`
+	ParentSpanID  string          `toml:"parent_span_id"`
+	Name          string          `toml:"name"`
+	Kind          string          `toml:"kind"`
+	ServiceName   string          `toml:"service_name"`
+	EndTime       string          `toml:"end_time"`
+	EndTimeFormat string          `toml:"end_time_format"`
+	Duration      string          `toml:"duration"`
+	DurationUnit  config.Duration `toml:"duration_unit"`
+	Status        string          `toml:"status"`
+	ErrorValues   []string        `toml:"error_values"`
+	OKValues      []string        `toml:"ok_values"`
+}
+
+func (m *SpanMapping) init() error {
+	if m.Measurement == "" {
+		return fmt.Errorf("span mapping measurement is required")
+	}
+	if m.EndTime != "" && m.Duration != "" {
+		return fmt.Errorf("span mapping %s: end_time and duration are mutually exclusive", m.Measurement)
+	}
+	if m.EndTimeFormat == "" {
+		m.EndTimeFormat = defaultEndTimeFormat
+	}
+	if m.DurationUnit <= 0 {
+		m.DurationUnit = config.Duration(time.Second)
+	}
+	if m.ErrorValues == nil {
+		m.ErrorValues = defaultErrorValues
+	}
+	if m.OKValues == nil {
+		m.OKValues = defaultOKValues
+	}
+	return nil
+}
+
+// span builds a span from metric. The keys the mapping uses are consumed,
+// the remaining tags and fields become span attributes.
+func (m *SpanMapping) span(metric telegraf.Metric) (ptrace.Span, error) {
+	span := ptrace.NewSpan()
+	span.SetStartTimestamp(pcommon.NewTimestampFromTime(metric.Time()))
+	span.SetKind(ptrace.SpanKind(SpanKindFromString(m.Kind)))
+	span.SetName(metric.Name())
+	if name, ok := lookup(metric, m.Name); ok {
+		span.SetName(fmt.Sprint(name))
+	}
+
+	var traceID pcommon.TraceID
+	if value, ok := lookup(metric, m.TraceID); ok {
+		copy(traceID[:], idFromValue(fmt.Sprint(value), len(traceID)))
+	} else {
+		copy(traceID[:], hashTags(metric, m.TraceIDTags, false))
+	}
+	span.SetTraceID(traceID)
+	var spanID pcommon.SpanID
+	if value, ok := lookup(metric, m.SpanID); ok {
+		copy(spanID[:], idFromValue(fmt.Sprint(value), len(spanID)))
+	} else {
+		copy(spanID[:], hashTags(metric, m.SpanIDTags, true))
+	}
+	span.SetSpanID(spanID)
+	if value, ok := lookup(metric, m.ParentSpanID); ok {
+		var parentSpanID pcommon.SpanID
+		copy(parentSpanID[:], idFromValue(fmt.Sprint(value), len(parentSpanID)))
+		span.SetParentSpanID(parentSpanID)
+	}
+
+	if value, ok := lookup(metric, m.EndTime); ok {
+		endTime, err := m.parseEndTime(value)
+		if err != nil {
+			return span, fmt.Errorf("span mapping %s: invalid end time %v: %w", m.Measurement, value, err)
+		}
+		span.SetEndTimestamp(pcommon.NewTimestampFromTime(endTime))
+	}
+	if value, ok := lookup(metric, m.Duration); ok {
+		duration, err := m.parseDuration(value)
+		if err != nil {
+			return span, fmt.Errorf("span mapping %s: invalid duration %v: %w", m.Measurement, value, err)
+		}
+		span.SetEndTimestamp(pcommon.NewTimestampFromTime(metric.Time().Add(duration)))
+	}
+	if value, ok := lookup(metric, m.Status); ok {
+		status := strings.ToLower(fmt.Sprint(value))
+		switch {
+		case slices.Contains(m.ErrorValues, status):
+			span.Status().SetCode(ptrace.StatusCodeError)
+			span.Status().SetMessage(fmt.Sprint(value))
+		case slices.Contains(m.OKValues, status):
+			span.Status().SetCode(ptrace.StatusCodeOk)
+		}
+	}
+
+	used := []string{m.TraceID, m.SpanID, m.ParentSpanID, m.Name, m.EndTime, m.Duration, m.Status}
+	attributes := span.Attributes()
+	for _, tag := range metric.TagList() {
+		if !slices.Contains(used, tag.Key) {
+			attributes.PutStr(tag.Key, tag.Value)
+		}
+	}
+	for _, field := range metric.FieldList() {
+		if slices.Contains(used, field.Key) {
+			continue
+		}
+		if err := attributes.PutEmpty(field.Key).FromRaw(field.Value); err != nil {
+			attributes.PutStr(field.Key, fmt.Sprint(field.Value))
+		}
+	}
+	if m.ServiceName != "" {
+		attributes.PutStr(semconv.AttributeServiceName, m.ServiceName)
+	}
+	return span, nil
+}
+
+// parseEndTime reads unix timestamps in the precision named by the format,
+// or strings in the format's Go layout.
+func (m *SpanMapping) parseEndTime(value interface{}) (time.Time, error) {
+	var unit time.Duration
+	switch m.EndTimeFormat {
+	case "unix":
+		unit = time.Second
+	case "unix_ms":
+		unit = time.Millisecond
+	case "unix_us":
+		unit = time.Microsecond
+	case "unix_ns":
+		unit = time.Nanosecond
+	default:
+		s, ok := value.(string)
+		if !ok {
+			return time.Time{}, fmt.Errorf("expected a string for format %q, got %T", m.EndTimeFormat, value)
+		}
+		return time.Parse(m.EndTimeFormat, s)
+	}
+	switch v := value.(type) {
+	case int64:
+		return time.Unix(0, v*int64(unit)), nil
+	case uint64:
+		return time.Unix(0, int64(v)*int64(unit)), nil
+	case float64:
+		return time.Unix(0, int64(v*float64(unit))), nil
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(0, int64(f*float64(unit))), nil
+	default:
+		return time.Time{}, fmt.Errorf("unsupported type %T", value)
+	}
+}
+
+func (m *SpanMapping) parseDuration(value interface{}) (time.Duration, error) {
+	unit := float64(m.DurationUnit)
+	switch v := value.(type) {
+	case int64:
+		return time.Duration(float64(v) * unit), nil
+	case uint64:
+		return time.Duration(float64(v) * unit), nil
+	case float64:
+		return time.Duration(v * unit), nil
+	case string:
+		return time.ParseDuration(v)
+	default:
+		return 0, fmt.Errorf("unsupported type %T", value)
+	}
+}
+
+// lookup returns the value of the tag, or else the field, named key.
+func lookup(metric telegraf.Metric, key string) (interface{}, bool) {
+	if key == "" {
+		return nil, false
+	}
+	if value, ok := metric.GetTag(key); ok {
+		return value, true
+	}
+	return metric.GetField(key)
+}
+
+// idFromValue uses value as the ID when it's hex of the right size, otherwise
+// the ID is derived from a hash of it. That way a parent ID refers to the
+// same span however the source spells its IDs.
+func idFromValue(value string, size int) []byte {
+	if id, err := hex.DecodeString(value); err == nil && len(id) == size {
+		return id
+	}
+	sum := sha256.Sum256([]byte(value))
+	return sum[:size]
+}
+
+// hashTags derives an ID from the values of tags, or of all the metric's tags
+// when none are given, so that rewriting the same point gives the same ID.
+// Span IDs also hash the point's time: otherwise they'd be the start of the
+// trace ID by default, and points with the same tags would share a span.
+func hashTags(metric telegraf.Metric, tags []string, withTime bool) []byte {
+	if len(tags) == 0 {
+		for _, tag := range metric.TagList() {
+			tags = append(tags, tag.Key)
+		}
+	}
+	tags = slices.Clone(tags)
+	sort.Strings(tags)
+	h := sha256.New()
+	for _, key := range tags {
+		value, _ := metric.GetTag(key)
+		fmt.Fprintf(h, "%s=%s\x00", key, value)
+	}
+	if withTime {
+		fmt.Fprintf(h, "%d", metric.Time().UnixNano())
+	}
+	return h.Sum(nil)
+}
@@ -0,0 +1,198 @@
+package oteltrace_test
+
+import (
+	"crypto/sha256"
+	"testing"
+	"time"
+
+	"github.com/catherinetcai/telegraf-execd-otel/plugins/outputs/oteltrace"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestOtelTraceSpanMapping(t *testing.T) {
+	server := &recordingTracesServer{}
+	ot := &oteltrace.OtelTrace{
+		ServiceAddress: startTracesServer(t, server),
+		SpanMappings: []*oteltrace.SpanMapping{
+			{
+				Measurement:  "jobs",
+				TraceIDTags:  []string{"pipeline", "run"},
+				SpanID:       "job_id",
+				ParentSpanID: "parent_job_id",
+				Name:         "job",
+				Duration:     "duration_ms",
+				DurationUnit: config.Duration(time.Millisecond),
+				Status:       "result",
+				ServiceName:  "ci",
+			},
+		},
+		Log: &testutil.Logger{},
+	}
+	require.NoError(t, ot.Init())
+	require.NoError(t, ot.Connect())
+	defer ot.Close()
+
+	start := time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
+	build := metric.New("jobs",
+		map[string]string{"pipeline": "release", "run": "42", "job": "build"},
+		map[string]interface{}{"job_id": "job-1", "duration_ms": int64(1500), "result": "success", "runner": "linux"},
+		start,
+	)
+	test := metric.New("jobs",
+		map[string]string{"pipeline": "release", "run": "42", "job": "test"},
+		map[string]interface{}{"job_id": "job-2", "parent_job_id": "job-1", "duration_ms": int64(500), "result": "FAILED"},
+		start.Add(2*time.Second),
+	)
+	require.NoError(t, ot.Write([]telegraf.Metric{build, test}))
+
+	spans := map[string]ptrace.Span{}
+	for _, request := range server.requests {
+		for i := 0; i < request.Traces().ResourceSpans().Len(); i++ {
+			rs := request.Traces().ResourceSpans().At(i)
+			span := rs.ScopeSpans().At(0).Spans().At(0)
+			serviceName, ok := rs.Resource().Attributes().Get("service.name")
+			require.True(t, ok)
+			assert.Equal(t, "ci", serviceName.AsString())
+			spans[span.Name()] = span
+		}
+	}
+	require.Len(t, spans, 2)
+
+	buildSpan, testSpan := spans["build"], spans["test"]
+	assert.Equal(t, buildSpan.TraceID(), testSpan.TraceID(), "spans of a run share a trace")
+	assert.Equal(t, buildSpan.SpanID(), testSpan.ParentSpanID())
+	hash := sha256.Sum256([]byte("job-1"))
+	assert.Equal(t, pcommon.SpanID(hash[:8]), buildSpan.SpanID())
+
+	assert.Equal(t, pcommon.NewTimestampFromTime(start), buildSpan.StartTimestamp())
+	assert.Equal(t, pcommon.NewTimestampFromTime(start.Add(1500*time.Millisecond)), buildSpan.EndTimestamp())
+	assert.Equal(t, ptrace.StatusCodeOk, buildSpan.Status().Code())
+	assert.Equal(t, ptrace.SpanKindInternal, buildSpan.Kind())
+	runner, ok := buildSpan.Attributes().Get("runner")
+	require.True(t, ok)
+	assert.Equal(t, "linux", runner.AsString())
+	_, ok = buildSpan.Attributes().Get("job_id")
+	assert.False(t, ok, "mapped keys aren't attributes")
+
+	assert.Equal(t, ptrace.StatusCodeError, testSpan.Status().Code())
+	assert.Equal(t, "FAILED", testSpan.Status().Message())
+
+	// Rewriting the same points gives the same IDs
+	require.NoError(t, ot.Write([]telegraf.Metric{build}))
+	again := server.requests[len(server.requests)-1].Traces().ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0)
+	assert.Equal(t, buildSpan.TraceID(), again.TraceID())
+	assert.Equal(t, buildSpan.SpanID(), again.SpanID())
+}
+
+func TestOtelTraceSpanMappingHexIDs(t *testing.T) {
+	server := &recordingTracesServer{}
+	ot := &oteltrace.OtelTrace{
+		ServiceAddress: startTracesServer(t, server),
+		SpanMappings: []*oteltrace.SpanMapping{
+			{
+				Measurement:   "requests",
+				TraceID:       "trace",
+				SpanIDTags:    []string{"request"},
+				EndTime:       "finished",
+				EndTimeFormat: "unix",
+				Kind:          "server",
+			},
+		},
+		Log: &testutil.Logger{},
+	}
+	require.NoError(t, ot.Init())
+	require.NoError(t, ot.Connect())
+	defer ot.Close()
+
+	start := time.Unix(1700000000, 0)
+	m := metric.New("requests",
+		map[string]string{"trace": "0102030405060708090a0b0c0d0e0f10", "request": "abc"},
+		map[string]interface{}{"finished": int64(1700000003)},
+		start,
+	)
+	require.NoError(t, ot.Write([]telegraf.Metric{m}))
+	require.Len(t, server.requests, 1)
+	span := server.requests[0].Traces().ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0)
+	assert.Equal(t, "0102030405060708090a0b0c0d0e0f10", span.TraceID().String())
+	assert.False(t, span.SpanID().IsEmpty())
+	assert.Equal(t, "requests", span.Name())
+	assert.Equal(t, ptrace.SpanKindServer, span.Kind())
+	assert.Equal(t, 3*time.Second, span.EndTimestamp().AsTime().Sub(span.StartTimestamp().AsTime()))
+}
+
+func TestOtelTraceSpanMappingHashedSpanIDs(t *testing.T) {
+	server := &recordingTracesServer{}
+	ot := &oteltrace.OtelTrace{
+		ServiceAddress: startTracesServer(t, server),
+		SpanMappings:   []*oteltrace.SpanMapping{{Measurement: "checks"}},
+		Log:            &testutil.Logger{},
+	}
+	require.NoError(t, ot.Init())
+	require.NoError(t, ot.Connect())
+	defer ot.Close()
+
+	start := time.Unix(1700000000, 0)
+	tags := map[string]string{"host": "db-1", "check": "disk"}
+	first := metric.New("checks", tags, map[string]interface{}{"ok": true}, start)
+	second := metric.New("checks", tags, map[string]interface{}{"ok": true}, start.Add(time.Minute))
+	require.NoError(t, ot.Write([]telegraf.Metric{first, second}))
+
+	var spans []ptrace.Span
+	for _, request := range server.requests {
+		for i := 0; i < request.Traces().ResourceSpans().Len(); i++ {
+			ss := request.Traces().ResourceSpans().At(i).ScopeSpans().At(0)
+			for j := 0; j < ss.Spans().Len(); j++ {
+				spans = append(spans, ss.Spans().At(j))
+			}
+		}
+	}
+	require.Len(t, spans, 2)
+	assert.Equal(t, spans[0].TraceID(), spans[1].TraceID())
+	assert.NotEqual(t, spans[0].SpanID(), spans[1].SpanID(), "points at different times are different spans")
+	for _, span := range spans {
+		traceID := span.TraceID()
+		assert.NotEqual(t, pcommon.SpanID(traceID[:8]), span.SpanID())
+	}
+}
+
+func TestOtelTraceSpanMappingInit(t *testing.T) {
+	tests := []struct {
+		name     string
+		mappings []*oteltrace.SpanMapping
+		err      string
+	}{
+		{
+			name:     "missing measurement",
+			mappings: []*oteltrace.SpanMapping{{}},
+			err:      "span mapping measurement is required",
+		},
+		{
+			name:     "otel2influx measurement",
+			mappings: []*oteltrace.SpanMapping{{Measurement: "spans"}},
+			err:      "measurement is already converted",
+		},
+		{
+			name:     "end time and duration",
+			mappings: []*oteltrace.SpanMapping{{Measurement: "jobs", EndTime: "end", Duration: "took"}},
+			err:      "end_time and duration are mutually exclusive",
+		},
+		{
+			name:     "duplicate",
+			mappings: []*oteltrace.SpanMapping{{Measurement: "jobs"}, {Measurement: "jobs"}},
+			err:      "duplicate span mapping for measurement jobs",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ot := &oteltrace.OtelTrace{SpanMappings: tt.mappings}
+			assert.ErrorContains(t, ot.Init(), tt.err)
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package oteltrace
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/pdatautil"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	semconv "go.opentelemetry.io/collector/semconv/v1.16.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Same names as the collector's spanmetrics connector so that existing
+// dashboards work unchanged.
+const (
+	defaultSpanMetricsNamespace = "traces.span.metrics"
+	defaultSpanMetricsInterval  = config.Duration(time.Minute)
+
+	spanMetricsSpanName   = "span.name"
+	spanMetricsSpanKind   = "span.kind"
+	spanMetricsStatusCode = "status.code"
+)
+
+var defaultSpanMetricsBuckets = []config.Duration{
+	config.Duration(2 * time.Millisecond),
+	config.Duration(4 * time.Millisecond),
+	config.Duration(6 * time.Millisecond),
+	config.Duration(8 * time.Millisecond),
+	config.Duration(10 * time.Millisecond),
+	config.Duration(50 * time.Millisecond),
+	config.Duration(100 * time.Millisecond),
+	config.Duration(200 * time.Millisecond),
+	config.Duration(400 * time.Millisecond),
+	config.Duration(800 * time.Millisecond),
+	config.Duration(time.Second),
+	config.Duration(1400 * time.Millisecond),
+	config.Duration(2 * time.Second),
+	config.Duration(5 * time.Second),
+	config.Duration(10 * time.Second),
+	config.Duration(15 * time.Second),
+}
+
+// SpanMetricsConfig enables RED metrics generated from the exported spans:
+// a call count and a duration histogram per service, span name, kind, status
+// and extra dimensions.
+type SpanMetricsConfig struct {
+	Interval       config.Duration   `toml:"interval"`
+	Namespace      string            `toml:"namespace"`
+	Buckets        []config.Duration `toml:"buckets"`
+	Dimensions     []string          `toml:"dimensions"`
+	ServiceAddress string            `toml:"service_address"`
+}
+
+func (c *SpanMetricsConfig) init() error {
+	if c.Interval <= 0 {
+		c.Interval = defaultSpanMetricsInterval
+	}
+	if c.Namespace == "" {
+		c.Namespace = defaultSpanMetricsNamespace
+	}
+	if len(c.Buckets) == 0 {
+		c.Buckets = defaultSpanMetricsBuckets
+	}
+	for i := 1; i < len(c.Buckets); i++ {
+		if c.Buckets[i] <= c.Buckets[i-1] {
+			return fmt.Errorf("span metrics buckets must be increasing")
+		}
+	}
+	return nil
+}
+
+// spanMetrics aggregates spans into cumulative series.
+type spanMetrics struct {
+	namespace  string
+	bounds     []float64
+	dimensions []string
+	startTime  pcommon.Timestamp
+
+	mu     sync.Mutex
+	series map[[16]byte]*spanMetricsSeries
+}
+
+type spanMetricsSeries struct {
+	serviceName  string
+	attributes   pcommon.Map
+	calls        uint64
+	bucketCounts []uint64
+	sum          float64
+}
+
+func newSpanMetrics(c *SpanMetricsConfig) *spanMetrics {
+	bounds := make([]float64, 0, len(c.Buckets))
+	for _, bucket := range c.Buckets {
+		bounds = append(bounds, durationMilliseconds(time.Duration(bucket)))
+	}
+	return &spanMetrics{
+		namespace:  c.Namespace,
+		bounds:     bounds,
+		dimensions: c.Dimensions,
+		startTime:  pcommon.NewTimestampFromTime(time.Now()),
+		series:     map[[16]byte]*spanMetricsSeries{},
+	}
+}
+
+func (s *spanMetrics) add(td ptrace.Traces) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		serviceName := ""
+		if v, ok := rs.Resource().Attributes().Get(semconv.AttributeServiceName); ok {
+			serviceName = v.AsString()
+		}
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			spans := rs.ScopeSpans().At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				s.addSpan(serviceName, rs.Resource(), spans.At(k))
+			}
+		}
+	}
+}
+
+func (s *spanMetrics) addSpan(serviceName string, resource pcommon.Resource, span ptrace.Span) {
+	attributes := pcommon.NewMap()
+	attributes.PutStr(semconv.AttributeServiceName, serviceName)
+	attributes.PutStr(spanMetricsSpanName, span.Name())
+	attributes.PutStr(spanMetricsSpanKind, spanKindName(span.Kind()))
+	attributes.PutStr(spanMetricsStatusCode, statusCodeName(span.Status().Code()))
+	for _, dimension := range s.dimensions {
+		if v, ok := span.Attributes().Get(dimension); ok {
+			v.CopyTo(attributes.PutEmpty(dimension))
+		} else if v, ok := resource.Attributes().Get(dimension); ok {
+			v.CopyTo(attributes.PutEmpty(dimension))
+		}
+	}
+
+	key := pdatautil.MapHash(attributes)
+	series, ok := s.series[key]
+	if !ok {
+		series = &spanMetricsSeries{
+			serviceName:  serviceName,
+			attributes:   attributes,
+			bucketCounts: make([]uint64, len(s.bounds)+1),
+		}
+		s.series[key] = series
+	}
+	duration := 0.0
+	if span.EndTimestamp() > span.StartTimestamp() {
+		duration = durationMilliseconds(time.Duration(span.EndTimestamp() - span.StartTimestamp()))
+	}
+	series.calls++
+	series.sum += duration
+	series.bucketCounts[sort.SearchFloat64s(s.bounds, duration)]++
+}
+
+// metrics returns the cumulative state of every series, one resource per
+// service, or false when no span has been seen yet.
+func (s *spanMetrics) metrics(now time.Time) (pmetric.Metrics, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	md := pmetric.NewMetrics()
+	if len(s.series) == 0 {
+		return md, false
+	}
+
+	type serviceMetrics struct {
+		calls    pmetric.Sum
+		duration pmetric.Histogram
+	}
+	services := map[string]serviceMetrics{}
+	timestamp := pcommon.NewTimestampFromTime(now)
+	for _, series := range s.series {
+		sm, ok := services[series.serviceName]
+		if !ok {
+			rm := md.ResourceMetrics().AppendEmpty()
+			rm.Resource().Attributes().PutStr(semconv.AttributeServiceName, series.serviceName)
+			scope := rm.ScopeMetrics().AppendEmpty()
+			scope.Scope().SetName("spanmetrics")
+
+			calls := scope.Metrics().AppendEmpty()
+			calls.SetName(s.namespace + ".calls")
+			sm.calls = calls.SetEmptySum()
+			sm.calls.SetIsMonotonic(true)
+			sm.calls.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+
+			duration := scope.Metrics().AppendEmpty()
+			duration.SetName(s.namespace + ".duration")
+			duration.SetUnit("ms")
+			sm.duration = duration.SetEmptyHistogram()
+			sm.duration.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+			services[series.serviceName] = sm
+		}
+
+		calls := sm.calls.DataPoints().AppendEmpty()
+		series.attributes.CopyTo(calls.Attributes())
+		calls.SetStartTimestamp(s.startTime)
+		calls.SetTimestamp(timestamp)
+		calls.SetIntValue(int64(series.calls))
+
+		duration := sm.duration.DataPoints().AppendEmpty()
+		series.attributes.CopyTo(duration.Attributes())
+		duration.SetStartTimestamp(s.startTime)
+		duration.SetTimestamp(timestamp)
+		duration.SetCount(series.calls)
+		duration.SetSum(series.sum)
+		duration.ExplicitBounds().FromRaw(s.bounds)
+		duration.BucketCounts().FromRaw(series.bucketCounts)
+	}
+	return md, true
+}
+
+func durationMilliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func spanKindName(kind ptrace.SpanKind) string {
+	return "SPAN_KIND_" + strings.ToUpper(trace.SpanKind(kind).String())
+}
+
+func statusCodeName(code ptrace.StatusCode) string {
+	switch code {
+	case ptrace.StatusCodeOk:
+		return "STATUS_CODE_OK"
+	case ptrace.StatusCodeError:
+		return "STATUS_CODE_ERROR"
+	default:
+		return "STATUS_CODE_UNSET"
+	}
+}
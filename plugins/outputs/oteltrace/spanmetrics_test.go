@@ -0,0 +1,151 @@
+package oteltrace_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/catherinetcai/telegraf-execd-otel/plugins/outputs/oteltrace"
+	influxcommon "github.com/influxdata/influxdb-observability/common"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	semconv "go.opentelemetry.io/collector/semconv/v1.16.0"
+	"google.golang.org/grpc"
+)
+
+// checkoutSpan returns a span of the checkout service lasting duration.
+func checkoutSpan(spanID int, duration time.Duration, statusCode string) telegraf.Metric {
+	m := generateTraceAsMetric()
+	m.AddTag(influxcommon.AttributeSpanID, fmt.Sprintf("%016x", spanID))
+	m.AddField(influxcommon.AttributeAttributes, `{"service.name":"checkout","http.route":"/pay"}`)
+	m.AddField(influxcommon.AttributeEndTimeUnixNano, m.Time().Add(duration).UnixNano())
+	m.AddField(semconv.OtelStatusCode, statusCode)
+	return m
+}
+
+func TestOtelTraceSpanMetrics(t *testing.T) {
+	metricsServer := &recordingMetricsServer{}
+	address := startServer(t, func(s *grpc.Server) {
+		ptraceotlp.RegisterGRPCServer(s, &recordingTracesServer{})
+		pmetricotlp.RegisterGRPCServer(s, metricsServer)
+	})
+	ot := &oteltrace.OtelTrace{
+		ServiceAddress: address,
+		SpanMetrics: &oteltrace.SpanMetricsConfig{
+			Interval:   config.Duration(time.Hour),
+			Buckets:    []config.Duration{config.Duration(10 * time.Millisecond), config.Duration(100 * time.Millisecond)},
+			Dimensions: []string{"http.route"},
+		},
+		Log: &testutil.Logger{},
+	}
+	require.NoError(t, ot.Init())
+	require.NoError(t, ot.Connect())
+
+	require.NoError(t, ot.Write([]telegraf.Metric{
+		checkoutSpan(1, 5*time.Millisecond, "STATUS_CODE_OK"),
+		checkoutSpan(2, 50*time.Millisecond, "STATUS_CODE_OK"),
+		checkoutSpan(3, 300*time.Millisecond, "STATUS_CODE_ERROR"),
+	}))
+	// The final state is sent on close
+	require.NoError(t, ot.Close())
+
+	require.Len(t, metricsServer.requests, 1)
+	rm := metricsServer.requests[0].Metrics().ResourceMetrics()
+	require.Equal(t, 1, rm.Len())
+	serviceName, ok := rm.At(0).Resource().Attributes().Get("service.name")
+	require.True(t, ok)
+	assert.Equal(t, "checkout", serviceName.AsString())
+	metrics := rm.At(0).ScopeMetrics().At(0).Metrics()
+	require.Equal(t, 2, metrics.Len())
+
+	calls := metrics.At(0)
+	assert.Equal(t, "traces.span.metrics.calls", calls.Name())
+	require.Equal(t, pmetric.MetricTypeSum, calls.Type())
+	callsByStatus := map[string]int64{}
+	for i := 0; i < calls.Sum().DataPoints().Len(); i++ {
+		dp := calls.Sum().DataPoints().At(i)
+		status, _ := dp.Attributes().Get("status.code")
+		route, _ := dp.Attributes().Get("http.route")
+		assert.Equal(t, "/pay", route.AsString())
+		kind, _ := dp.Attributes().Get("span.kind")
+		assert.Equal(t, "SPAN_KIND_SERVER", kind.AsString())
+		callsByStatus[status.AsString()] = dp.IntValue()
+	}
+	assert.Equal(t, map[string]int64{"STATUS_CODE_OK": 2, "STATUS_CODE_ERROR": 1}, callsByStatus)
+
+	duration := metrics.At(1)
+	assert.Equal(t, "traces.span.metrics.duration", duration.Name())
+	assert.Equal(t, "ms", duration.Unit())
+	require.Equal(t, pmetric.MetricTypeHistogram, duration.Type())
+	for i := 0; i < duration.Histogram().DataPoints().Len(); i++ {
+		dp := duration.Histogram().DataPoints().At(i)
+		assert.Equal(t, []float64{10, 100}, dp.ExplicitBounds().AsRaw())
+		status, _ := dp.Attributes().Get("status.code")
+		if status.AsString() == "STATUS_CODE_OK" {
+			assert.Equal(t, []uint64{1, 1, 0}, dp.BucketCounts().AsRaw())
+			assert.InDelta(t, 55, dp.Sum(), 0.001)
+		} else {
+			assert.Equal(t, []uint64{0, 0, 1}, dp.BucketCounts().AsRaw())
+		}
+	}
+}
+
+func TestOtelTraceSpanMetricsInit(t *testing.T) {
+	ot := &oteltrace.OtelTrace{
+		SpanMetrics: &oteltrace.SpanMetricsConfig{
+			Buckets: []config.Duration{config.Duration(time.Second), config.Duration(time.Millisecond)},
+		},
+	}
+	assert.ErrorContains(t, ot.Init(), "span metrics buckets must be increasing")
+
+	ot = &oteltrace.OtelTrace{
+		SpanMetrics: &oteltrace.SpanMetricsConfig{},
+		Exporters:   []*oteltrace.ExporterConfig{{Name: "debug", Type: "debug"}},
+	}
+	assert.ErrorContains(t, ot.Init(), "span metrics need a service_address or at least one otlp exporter")
+}
+
+func TestOtelTraceSpanMetricsRetriedBatch(t *testing.T) {
+	metricsServer := &recordingMetricsServer{}
+	address := startServer(t, func(s *grpc.Server) {
+		ptraceotlp.RegisterGRPCServer(s, &recordingTracesServer{})
+		pmetricotlp.RegisterGRPCServer(s, metricsServer)
+	})
+	ot := &oteltrace.OtelTrace{
+		ServiceAddress: address,
+		Routes: []*oteltrace.Route{{
+			Name:           "flaky",
+			Key:            "http.route",
+			Values:         []string{"/refund"},
+			ServiceAddress: startTracesServer(t, &recordingTracesServer{rejectFirst: 1}),
+		}},
+		SpanMetrics: &oteltrace.SpanMetricsConfig{Interval: config.Duration(time.Hour)},
+		Log:         &testutil.Logger{Quiet: true},
+	}
+	require.NoError(t, ot.Init())
+	require.NoError(t, ot.Connect())
+
+	refund := checkoutSpan(2, 5*time.Millisecond, "STATUS_CODE_OK")
+	refund.AddField(influxcommon.AttributeAttributes, `{"service.name":"checkout","http.route":"/refund"}`)
+	batch := []telegraf.Metric{checkoutSpan(1, 5*time.Millisecond, "STATUS_CODE_OK"), refund}
+	// Telegraf writes the whole batch again after the failure, which mustn't
+	// count the span that was exported the first time twice
+	require.Error(t, ot.Write(batch))
+	require.NoError(t, ot.Write(batch))
+	require.NoError(t, ot.Close())
+
+	require.Len(t, metricsServer.requests, 1)
+	calls := metricsServer.requests[0].Metrics().ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0)
+	require.Equal(t, "traces.span.metrics.calls", calls.Name())
+	var total int64
+	for i := 0; i < calls.Sum().DataPoints().Len(); i++ {
+		total += calls.Sum().DataPoints().At(i).IntValue()
+	}
+	assert.Equal(t, int64(2), total)
+}
@@ -0,0 +1,123 @@
+package oteltrace
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+const defaultOriginalNameAttribute = "span.original_name"
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// SpanNameNormalization rewrites high cardinality span names such as
+// "GET /users/12345". Names are derived from http.route when enabled, then
+// the rules run in order, then the built-in heuristics replace path segments
+// that are numbers, UUIDs or hex IDs. A name that changed is kept in the
+// OriginalNameAttribute attribute.
+type SpanNameNormalization struct {
+	UseHTTPRoute          bool            `toml:"use_http_route"`
+	Rules                 []*SpanNameRule `toml:"rule"`
+	Numbers               bool            `toml:"numbers"`
+	UUIDs                 bool            `toml:"uuids"`
+	HexIDs                bool            `toml:"hex_ids"`
+	OriginalNameAttribute string          `toml:"original_name_attribute"`
+}
+
+// SpanNameRule replaces the matches of Pattern in span names with
+// Replacement, which can refer to the pattern's groups.
+type SpanNameRule struct {
+	Pattern     string `toml:"pattern"`
+	Replacement string `toml:"replacement"`
+
+	pattern *regexp.Regexp
+}
+
+func (n *SpanNameNormalization) init() error {
+	for _, rule := range n.Rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("span name rule: invalid pattern %q: %w", rule.Pattern, err)
+		}
+		rule.pattern = re
+	}
+	if n.OriginalNameAttribute == "" {
+		n.OriginalNameAttribute = defaultOriginalNameAttribute
+	}
+	return nil
+}
+
+func (n *SpanNameNormalization) processTraces(td ptrace.Traces) {
+	forEachSpan(td, func(_ pcommon.Resource, span ptrace.Span) {
+		original := span.Name()
+		name := n.normalize(span)
+		if name == original {
+			return
+		}
+		span.SetName(name)
+		if _, ok := span.Attributes().Get(n.OriginalNameAttribute); !ok {
+			span.Attributes().PutStr(n.OriginalNameAttribute, original)
+		}
+	})
+}
+
+func (n *SpanNameNormalization) normalize(span ptrace.Span) string {
+	name := span.Name()
+	if n.UseHTTPRoute {
+		if route, ok := span.Attributes().Get("http.route"); ok && route.Str() != "" {
+			name = route.Str()
+			for _, key := range []string{"http.request.method", "http.method"} {
+				if method, ok := span.Attributes().Get(key); ok {
+					name = method.AsString() + " " + name
+					break
+				}
+			}
+		}
+	}
+	for _, rule := range n.Rules {
+		name = rule.pattern.ReplaceAllString(name, rule.Replacement)
+	}
+	if !n.Numbers && !n.UUIDs && !n.HexIDs {
+		return name
+	}
+	segments := strings.Split(name, "/")
+	for i, segment := range segments {
+		segments[i] = n.placeholder(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// placeholder returns the placeholder replacing segment, or segment when it
+// isn't an ID.
+func (n *SpanNameNormalization) placeholder(segment string) string {
+	switch {
+	case segment == "":
+		return segment
+	case n.Numbers && isNumber(segment):
+		return "{id}"
+	case n.UUIDs && uuidPattern.MatchString(segment):
+		return "{uuid}"
+	case n.HexIDs && isHexID(segment):
+		return "{hex}"
+	default:
+		return segment
+	}
+}
+
+func isNumber(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' }) < 0
+}
+
+// isHexID reports whether s looks like a hex ID rather than a word: at least
+// 8 hex digits, including at least one decimal digit.
+func isHexID(s string) bool {
+	if len(s) < 8 || strings.IndexAny(s, "0123456789") < 0 {
+		return false
+	}
+	return strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f' || r >= 'A' && r <= 'F')
+	}) < 0
+}
@@ -0,0 +1,66 @@
+package oteltrace_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/catherinetcai/telegraf-execd-otel/plugins/outputs/oteltrace"
+	influxcommon "github.com/influxdata/influxdb-observability/common"
+	"github.com/influxdata/telegraf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOtelTraceSpanNameNormalization(t *testing.T) {
+	ot := &oteltrace.OtelTrace{
+		SpanNames: &oteltrace.SpanNameNormalization{
+			UseHTTPRoute: true,
+			Rules:        []*oteltrace.SpanNameRule{{Pattern: `\?.*$`, Replacement: ""}},
+			Numbers:      true,
+			UUIDs:        true,
+			HexIDs:       true,
+		},
+	}
+	names := map[string]string{
+		"GET /users/12345": `{}`,
+		"GET /orders/6f1c0a3e-9d2b-4c41-8e7a-2b1f3c4d5e6f/items?page=2": `{}`,
+		"GET /commits/9fceb02d0ae598e95dc970b74767f19372d61af8":         `{}`,
+		"GET /feeds/deadbeef":    `{}`,
+		"GET /accounts/42/roles": `{"http.route":"/accounts/:id/roles","http.request.method":"GET"}`,
+		"SELECT users":           `{}`,
+	}
+	var metrics []telegraf.Metric
+	spanID := 1
+	for name, attributes := range names {
+		m := attributesSpan(name, attributes)
+		m.AddTag(influxcommon.AttributeSpanID, fmt.Sprintf("%016x", spanID))
+		spanID++
+		metrics = append(metrics, m)
+	}
+	td := writeTraces(t, ot, metrics...)
+	require.Equal(t, len(names), td.SpanCount())
+
+	normalized := map[string]any{}
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		span := td.ResourceSpans().At(i).ScopeSpans().At(0).Spans().At(0)
+		normalized[span.Name()] = nil
+		if original, ok := span.Attributes().Get("span.original_name"); ok {
+			normalized[span.Name()] = original.AsString()
+		}
+	}
+	assert.Equal(t, map[string]any{
+		"GET /users/{id}":          "GET /users/12345",
+		"GET /orders/{uuid}/items": "GET /orders/6f1c0a3e-9d2b-4c41-8e7a-2b1f3c4d5e6f/items?page=2",
+		"GET /commits/{hex}":       "GET /commits/9fceb02d0ae598e95dc970b74767f19372d61af8",
+		"GET /feeds/deadbeef":      nil,
+		"GET /accounts/:id/roles":  "GET /accounts/42/roles",
+		"SELECT users":             nil,
+	}, normalized)
+}
+
+func TestOtelTraceSpanNameNormalizationInit(t *testing.T) {
+	ot := &oteltrace.OtelTrace{
+		SpanNames: &oteltrace.SpanNameNormalization{Rules: []*oteltrace.SpanNameRule{{Pattern: "("}}},
+	}
+	assert.ErrorContains(t, ot.Init(), `span name rule: invalid pattern "("`)
+}
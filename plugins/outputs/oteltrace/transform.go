@@ -0,0 +1,505 @@
+package oteltrace
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// Transform contexts, from the outermost to the innermost.
+const (
+	transformContextResource = "resource"
+	transformContextScope    = "scope"
+	transformContextSpan     = "span"
+	transformContextEvent    = "event"
+	transformContextLink     = "link"
+)
+
+// transformVisible lists, for each context, the contexts whose paths its
+// statements can use. Paths without a context prefix belong to the first.
+var transformVisible = map[string][]string{
+	transformContextResource: {transformContextResource},
+	transformContextScope:    {transformContextScope, transformContextResource},
+	transformContextSpan:     {transformContextSpan, transformContextScope, transformContextResource},
+	transformContextEvent:    {transformContextEvent, transformContextSpan, transformContextScope, transformContextResource},
+	transformContextLink:     {transformContextLink, transformContextSpan, transformContextScope, transformContextResource},
+}
+
+// TransformProcessor runs statements in the style of the collector's OTTL
+// against every resource, scope, span, event or link, depending on Context.
+type TransformProcessor struct {
+	Context    string   `toml:"context"`
+	Statements []string `toml:"statements"`
+
+	statements []*transformStatement
+	log        telegraf.Logger
+}
+
+func (p *TransformProcessor) init(log telegraf.Logger) error {
+	if _, ok := transformVisible[p.Context]; !ok {
+		return fmt.Errorf("invalid transform context %q", p.Context)
+	}
+	if len(p.Statements) == 0 {
+		return fmt.Errorf("transform %s: at least one statement is required", p.Context)
+	}
+	for _, statement := range p.Statements {
+		s, err := parseTransformStatement(statement, p.Context)
+		if err != nil {
+			return fmt.Errorf("transform %s statement %q: %w", p.Context, statement, err)
+		}
+		p.statements = append(p.statements, s)
+	}
+	p.log = log
+	return nil
+}
+
+// transformContext is what a statement runs against. Only the fields of the
+// processor's context and the ones enclosing it are set.
+type transformContext struct {
+	resource pcommon.Resource
+	scope    pcommon.InstrumentationScope
+	span     ptrace.Span
+	event    ptrace.SpanEvent
+	link     ptrace.SpanLink
+}
+
+func (p *TransformProcessor) processTraces(td ptrace.Traces) {
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		if p.Context == transformContextResource {
+			p.run(&transformContext{resource: rs.Resource()})
+			continue
+		}
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			ss := rs.ScopeSpans().At(j)
+			if p.Context == transformContextScope {
+				p.run(&transformContext{resource: rs.Resource(), scope: ss.Scope()})
+				continue
+			}
+			for k := 0; k < ss.Spans().Len(); k++ {
+				ctx := &transformContext{resource: rs.Resource(), scope: ss.Scope(), span: ss.Spans().At(k)}
+				switch p.Context {
+				case transformContextSpan:
+					p.run(ctx)
+				case transformContextEvent:
+					for l := 0; l < ctx.span.Events().Len(); l++ {
+						ctx.event = ctx.span.Events().At(l)
+						p.run(ctx)
+					}
+				case transformContextLink:
+					for l := 0; l < ctx.span.Links().Len(); l++ {
+						ctx.link = ctx.span.Links().At(l)
+						p.run(ctx)
+					}
+				}
+			}
+		}
+	}
+}
+
+// run applies the statements in order. A statement that fails is logged and
+// skipped, the others still run.
+func (p *TransformProcessor) run(ctx *transformContext) {
+	for _, s := range p.statements {
+		if s.condition != nil && !s.condition(ctx) {
+			continue
+		}
+		if err := s.editor(ctx); err != nil {
+			p.log.Debugf("transform %s statement %q failed: %s", p.Context, s.text, err)
+		}
+	}
+}
+
+// pathExpr is a field of the telemetry. Attribute maps can be indexed, the
+// resulting path reads and writes a single attribute.
+type pathExpr struct {
+	name       string
+	get        func(ctx *transformContext) any
+	set        func(ctx *transformContext, v any) error
+	attributes func(ctx *transformContext) pcommon.Map
+}
+
+func (e *pathExpr) eval(ctx *transformContext) any {
+	return e.get(ctx)
+}
+
+func (e *pathExpr) index(key string) *pathExpr {
+	attributes := e.attributes
+	return &pathExpr{
+		name: fmt.Sprintf("%s[%q]", e.name, key),
+		get: func(ctx *transformContext) any {
+			if v, ok := attributes(ctx).Get(key); ok {
+				return v.AsRaw()
+			}
+			return nil
+		},
+		set: func(ctx *transformContext, v any) error {
+			if v == nil {
+				attributes(ctx).Remove(key)
+				return nil
+			}
+			return attributes(ctx).PutEmpty(key).FromRaw(v)
+		},
+	}
+}
+
+func attributesPath(name string, attributes func(ctx *transformContext) pcommon.Map) *pathExpr {
+	return &pathExpr{
+		name:       name,
+		get:        func(ctx *transformContext) any { return attributes(ctx).AsRaw() },
+		attributes: attributes,
+	}
+}
+
+func stringPath(name string, get func(ctx *transformContext) string, set func(ctx *transformContext, s string)) *pathExpr {
+	path := &pathExpr{
+		name: name,
+		get:  func(ctx *transformContext) any { return get(ctx) },
+	}
+	if set != nil {
+		path.set = func(ctx *transformContext, v any) error {
+			s, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("%s needs a string, got %T", name, v)
+			}
+			set(ctx, s)
+			return nil
+		}
+	}
+	return path
+}
+
+func intPath(name string, get func(ctx *transformContext) int64, set func(ctx *transformContext, i int64)) *pathExpr {
+	path := &pathExpr{
+		name: name,
+		get:  func(ctx *transformContext) any { return get(ctx) },
+	}
+	if set != nil {
+		path.set = func(ctx *transformContext, v any) error {
+			i, ok := v.(int64)
+			if !ok {
+				return fmt.Errorf("%s needs an integer, got %T", name, v)
+			}
+			set(ctx, i)
+			return nil
+		}
+	}
+	return path
+}
+
+// transformPaths are the fields of each context, by their full name.
+var transformPaths = map[string]*pathExpr{
+	"resource.attributes": attributesPath("resource.attributes", func(ctx *transformContext) pcommon.Map { return ctx.resource.Attributes() }),
+
+	"scope.name": stringPath("scope.name",
+		func(ctx *transformContext) string { return ctx.scope.Name() },
+		func(ctx *transformContext, s string) { ctx.scope.SetName(s) }),
+	"scope.version": stringPath("scope.version",
+		func(ctx *transformContext) string { return ctx.scope.Version() },
+		func(ctx *transformContext, s string) { ctx.scope.SetVersion(s) }),
+	"scope.attributes": attributesPath("scope.attributes", func(ctx *transformContext) pcommon.Map { return ctx.scope.Attributes() }),
+
+	"span.name": stringPath("span.name",
+		func(ctx *transformContext) string { return ctx.span.Name() },
+		func(ctx *transformContext, s string) { ctx.span.SetName(s) }),
+	"span.kind": intPath("span.kind",
+		func(ctx *transformContext) int64 { return int64(ctx.span.Kind()) },
+		func(ctx *transformContext, i int64) { ctx.span.SetKind(ptrace.SpanKind(i)) }),
+	"span.status.code": intPath("span.status.code",
+		func(ctx *transformContext) int64 { return int64(ctx.span.Status().Code()) },
+		func(ctx *transformContext, i int64) { ctx.span.Status().SetCode(ptrace.StatusCode(i)) }),
+	"span.status.message": stringPath("span.status.message",
+		func(ctx *transformContext) string { return ctx.span.Status().Message() },
+		func(ctx *transformContext, s string) { ctx.span.Status().SetMessage(s) }),
+	"span.trace_id": stringPath("span.trace_id",
+		func(ctx *transformContext) string { return ctx.span.TraceID().String() }, nil),
+	"span.span_id": stringPath("span.span_id",
+		func(ctx *transformContext) string { return ctx.span.SpanID().String() }, nil),
+	"span.parent_span_id": stringPath("span.parent_span_id",
+		func(ctx *transformContext) string { return ctx.span.ParentSpanID().String() }, nil),
+	"span.start_time_unix_nano": intPath("span.start_time_unix_nano",
+		func(ctx *transformContext) int64 { return int64(ctx.span.StartTimestamp()) }, nil),
+	"span.end_time_unix_nano": intPath("span.end_time_unix_nano",
+		func(ctx *transformContext) int64 { return int64(ctx.span.EndTimestamp()) }, nil),
+	"span.attributes": attributesPath("span.attributes", func(ctx *transformContext) pcommon.Map { return ctx.span.Attributes() }),
+
+	"event.name": stringPath("event.name",
+		func(ctx *transformContext) string { return ctx.event.Name() },
+		func(ctx *transformContext, s string) { ctx.event.SetName(s) }),
+	"event.time_unix_nano": intPath("event.time_unix_nano",
+		func(ctx *transformContext) int64 { return int64(ctx.event.Timestamp()) }, nil),
+	"event.attributes": attributesPath("event.attributes", func(ctx *transformContext) pcommon.Map { return ctx.event.Attributes() }),
+
+	"link.trace_id": stringPath("link.trace_id",
+		func(ctx *transformContext) string { return ctx.link.TraceID().String() }, nil),
+	"link.span_id": stringPath("link.span_id",
+		func(ctx *transformContext) string { return ctx.link.SpanID().String() }, nil),
+	"link.trace_state": stringPath("link.trace_state",
+		func(ctx *transformContext) string { return ctx.link.TraceState().AsRaw() },
+		func(ctx *transformContext, s string) { ctx.link.TraceState().FromRaw(s) }),
+	"link.attributes": attributesPath("link.attributes", func(ctx *transformContext) pcommon.Map { return ctx.link.Attributes() }),
+}
+
+// resolveTransformPath looks name up in the context, or in one of the
+// contexts visible from it when name starts with its prefix.
+func resolveTransformPath(context, name string) (*pathExpr, error) {
+	visible := transformVisible[context]
+	if prefix, _, ok := strings.Cut(name, "."); ok && slices.Contains(visible, prefix) {
+		if path, ok := transformPaths[name]; ok {
+			return path, nil
+		}
+	}
+	if path, ok := transformPaths[context+"."+name]; ok {
+		return path, nil
+	}
+	return nil, fmt.Errorf("unknown path %s in the %s context", name, context)
+}
+
+// Editors are the functions statements call. They check their arguments
+// when the statement is parsed.
+var transformEditors = map[string]func(args []transformExpr) (func(ctx *transformContext) error, error){
+	"set": func(args []transformExpr) (func(ctx *transformContext) error, error) {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("expected 2 arguments, got %d", len(args))
+		}
+		target, err := settablePath(args[0])
+		if err != nil {
+			return nil, err
+		}
+		value := args[1]
+		return func(ctx *transformContext) error {
+			return target.set(ctx, value.eval(ctx))
+		}, nil
+	},
+	"delete_key": func(args []transformExpr) (func(ctx *transformContext) error, error) {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("expected 2 arguments, got %d", len(args))
+		}
+		target, err := mapPath(args[0])
+		if err != nil {
+			return nil, err
+		}
+		key, ok := stringLiteral(args[1])
+		if !ok {
+			return nil, fmt.Errorf("the key must be a string")
+		}
+		return func(ctx *transformContext) error {
+			target.attributes(ctx).Remove(key)
+			return nil
+		}, nil
+	},
+	"delete_matching_keys": func(args []transformExpr) (func(ctx *transformContext) error, error) {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("expected 2 arguments, got %d", len(args))
+		}
+		target, err := mapPath(args[0])
+		if err != nil {
+			return nil, err
+		}
+		re, err := regexpLiteral(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx *transformContext) error {
+			target.attributes(ctx).RemoveIf(func(k string, _ pcommon.Value) bool { return re.MatchString(k) })
+			return nil
+		}, nil
+	},
+	"keep_keys": func(args []transformExpr) (func(ctx *transformContext) error, error) {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("expected 2 arguments, got %d", len(args))
+		}
+		target, err := mapPath(args[0])
+		if err != nil {
+			return nil, err
+		}
+		list, ok := args[1].(listExpr)
+		if !ok {
+			return nil, fmt.Errorf("the keys must be a list of strings")
+		}
+		keys := make([]string, 0, len(list))
+		for _, item := range list {
+			key, ok := stringLiteral(item)
+			if !ok {
+				return nil, fmt.Errorf("the keys must be a list of strings")
+			}
+			keys = append(keys, key)
+		}
+		return func(ctx *transformContext) error {
+			target.attributes(ctx).RemoveIf(func(k string, _ pcommon.Value) bool { return !slices.Contains(keys, k) })
+			return nil
+		}, nil
+	},
+	"truncate_all": func(args []transformExpr) (func(ctx *transformContext) error, error) {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("expected 2 arguments, got %d", len(args))
+		}
+		target, err := mapPath(args[0])
+		if err != nil {
+			return nil, err
+		}
+		literal, ok := args[1].(literalExpr)
+		limit, isInt := literal.value.(int64)
+		if !ok || !isInt || limit < 0 {
+			return nil, fmt.Errorf("the limit must be a non-negative integer")
+		}
+		return func(ctx *transformContext) error {
+			target.attributes(ctx).Range(func(_ string, v pcommon.Value) bool {
+				if v.Type() == pcommon.ValueTypeStr {
+					truncateValue(v, int(limit))
+				}
+				return true
+			})
+			return nil
+		}, nil
+	},
+	"replace_pattern": func(args []transformExpr) (func(ctx *transformContext) error, error) {
+		if len(args) != 3 {
+			return nil, fmt.Errorf("expected 3 arguments, got %d", len(args))
+		}
+		target, err := settablePath(args[0])
+		if err != nil {
+			return nil, err
+		}
+		re, err := regexpLiteral(args[1])
+		if err != nil {
+			return nil, err
+		}
+		replacement, ok := stringLiteral(args[2])
+		if !ok {
+			return nil, fmt.Errorf("the replacement must be a string")
+		}
+		return func(ctx *transformContext) error {
+			s, ok := target.get(ctx).(string)
+			if !ok {
+				return nil
+			}
+			return target.set(ctx, re.ReplaceAllString(s, replacement))
+		}, nil
+	},
+}
+
+// Converters are the functions usable as values.
+var transformConverters = map[string]func(args []transformExpr) (transformExpr, error){
+	"Concat": func(args []transformExpr) (transformExpr, error) {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("expected 2 arguments, got %d", len(args))
+		}
+		list, ok := args[0].(listExpr)
+		if !ok {
+			return nil, fmt.Errorf("the values must be a list")
+		}
+		separator, ok := stringLiteral(args[1])
+		if !ok {
+			return nil, fmt.Errorf("the separator must be a string")
+		}
+		return funcExpr(func(ctx *transformContext) any {
+			values := make([]string, 0, len(list))
+			for _, item := range list {
+				if v := item.eval(ctx); v != nil {
+					values = append(values, fmt.Sprint(v))
+				}
+			}
+			return strings.Join(values, separator)
+		}), nil
+	},
+	"IsMatch": func(args []transformExpr) (transformExpr, error) {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("expected 2 arguments, got %d", len(args))
+		}
+		re, err := regexpLiteral(args[1])
+		if err != nil {
+			return nil, err
+		}
+		value := args[0]
+		return funcExpr(func(ctx *transformContext) any {
+			s, ok := value.eval(ctx).(string)
+			return ok && re.MatchString(s)
+		}), nil
+	},
+	"Int": func(args []transformExpr) (transformExpr, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+		}
+		value := args[0]
+		return funcExpr(func(ctx *transformContext) any {
+			switch v := value.eval(ctx).(type) {
+			case int64:
+				return v
+			case float64:
+				return int64(v)
+			case bool:
+				if v {
+					return int64(1)
+				}
+				return int64(0)
+			case string:
+				if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+					return i
+				}
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					return int64(f)
+				}
+			}
+			return nil
+		}), nil
+	},
+	"String": func(args []transformExpr) (transformExpr, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+		}
+		value := args[0]
+		return funcExpr(func(ctx *transformContext) any {
+			if v := value.eval(ctx); v != nil {
+				return fmt.Sprint(v)
+			}
+			return nil
+		}), nil
+	},
+}
+
+func settablePath(arg transformExpr) (*pathExpr, error) {
+	path, ok := arg.(*pathExpr)
+	if !ok {
+		return nil, fmt.Errorf("the target must be a path")
+	}
+	if path.set == nil {
+		return nil, fmt.Errorf("%s can't be set", path.name)
+	}
+	return path, nil
+}
+
+func mapPath(arg transformExpr) (*pathExpr, error) {
+	path, ok := arg.(*pathExpr)
+	if !ok || path.attributes == nil {
+		return nil, fmt.Errorf("the target must be an attributes map")
+	}
+	return path, nil
+}
+
+func stringLiteral(arg transformExpr) (string, bool) {
+	literal, ok := arg.(literalExpr)
+	if !ok {
+		return "", false
+	}
+	s, ok := literal.value.(string)
+	return s, ok
+}
+
+func regexpLiteral(arg transformExpr) (*regexp.Regexp, error) {
+	pattern, ok := stringLiteral(arg)
+	if !ok {
+		return nil, fmt.Errorf("the pattern must be a string")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	return re, nil
+}
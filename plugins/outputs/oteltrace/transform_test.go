@@ -0,0 +1,145 @@
+package oteltrace_test
+
+import (
+	"testing"
+
+	"github.com/catherinetcai/telegraf-execd-otel/plugins/outputs/oteltrace"
+	influxcommon "github.com/influxdata/influxdb-observability/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestOtelTraceTransform(t *testing.T) {
+	ot := &oteltrace.OtelTrace{
+		Transforms: []*oteltrace.TransformProcessor{
+			{
+				Context: "span",
+				Statements: []string{
+					`set(status.code, STATUS_CODE_ERROR) where attributes["http.status_code"] >= 500`,
+					`set(status.message, Concat(["HTTP", String(Int(attributes["http.status_code"]))], " ")) where status.code == STATUS_CODE_ERROR`,
+					`set(attributes["http.request.method"], attributes["http.method"]) where attributes["http.method"] != nil`,
+					`delete_key(attributes, "http.method")`,
+					`truncate_all(attributes, 12)`,
+					`replace_pattern(name, "/[0-9]+", "/{id}") where kind == SPAN_KIND_SERVER and not IsMatch(name, "^GET /health")`,
+					`set(attributes["tier"], resource.attributes["tier"])`,
+				},
+			},
+			{
+				Context:    "resource",
+				Statements: []string{`keep_keys(attributes, ["service.name"])`},
+			},
+		},
+	}
+	healthz := attributesSpan("GET /healthz/1", `{"service.name":"users","http.status_code":200}`)
+	healthz.AddTag(influxcommon.AttributeSpanID, "0000000000000002")
+	td := writeTraces(t, ot,
+		attributesSpan("GET /users/12345", `{"service.name":"users","tier":"gold","http.status_code":503,"http.method":"GET","db.statement":"SELECT * FROM users WHERE id = 12345","db.user":"jürgen-müller-schmidt"}`),
+		healthz,
+	)
+	require.Equal(t, 2, td.SpanCount())
+
+	spans := map[string]ptrace.Span{}
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		assert.Equal(t, map[string]any{"service.name": "users"}, rs.Resource().Attributes().AsRaw())
+		span := rs.ScopeSpans().At(0).Spans().At(0)
+		spans[span.Name()] = span
+	}
+
+	require.Contains(t, spans, "GET /users/{id}")
+	failed := spans["GET /users/{id}"]
+	assert.Equal(t, ptrace.StatusCodeError, failed.Status().Code())
+	assert.Equal(t, "HTTP 503", failed.Status().Message())
+	assert.Equal(t, map[string]any{
+		"service.name":        "users",
+		"tier":                "gold",
+		"http.status_code":    int64(503),
+		"http.request.method": "GET",
+		"db.statement":        "SELECT * FRO",
+		"db.user":             "jürgen-mülle",
+	}, failed.Attributes().AsRaw())
+
+	require.Contains(t, spans, "GET /healthz/1")
+	ok := spans["GET /healthz/1"]
+	assert.NotEqual(t, ptrace.StatusCodeError, ok.Status().Code())
+	assert.NotContains(t, ok.Attributes().AsRaw(), "tier")
+}
+
+func TestOtelTraceTransformInit(t *testing.T) {
+	tests := []struct {
+		name      string
+		context   string
+		statement string
+		err       string
+	}{
+		{
+			name:      "unknown context",
+			context:   "metric",
+			statement: `set(name, "a")`,
+			err:       `invalid transform context "metric"`,
+		},
+		{
+			name:      "unknown function",
+			context:   "span",
+			statement: `rename(name, "a")`,
+			err:       `transform span statement "rename(name, \"a\")": column 1: unknown function "rename"`,
+		},
+		{
+			name:      "unknown path",
+			context:   "resource",
+			statement: `set(name, "a")`,
+			err:       "column 5: unknown path name in the resource context",
+		},
+		{
+			name:      "inner context",
+			context:   "span",
+			statement: `set(event.name, "a")`,
+			err:       "unknown path event.name in the span context",
+		},
+		{
+			name:      "read only",
+			context:   "span",
+			statement: `set(trace_id, "a")`,
+			err:       "set: span.trace_id can't be set",
+		},
+		{
+			name:      "missing parenthesis",
+			context:   "span",
+			statement: `set(name, "a" where kind == SPAN_KIND_SERVER`,
+			err:       `column 15: expected ",", got "where"`,
+		},
+		{
+			name:      "trailing input",
+			context:   "span",
+			statement: `set(name, "a") where kind == SPAN_KIND_SERVER )`,
+			err:       `column 47: unexpected ")"`,
+		},
+		{
+			name:      "unterminated string",
+			context:   "span",
+			statement: `set(name, "a)`,
+			err:       "column 11: unterminated string",
+		},
+		{
+			name:      "bad pattern",
+			context:   "span",
+			statement: `replace_pattern(name, "(", "")`,
+			err:       `replace_pattern: invalid pattern "("`,
+		},
+		{
+			name:      "wrong arity",
+			context:   "span",
+			statement: `set(name)`,
+			err:       "set: expected 2 arguments, got 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ot := &oteltrace.OtelTrace{
+				Transforms: []*oteltrace.TransformProcessor{{Context: tt.context, Statements: []string{tt.statement}}},
+			}
+			assert.ErrorContains(t, ot.Init(), tt.err)
+		})
+	}
+}
@@ -0,0 +1,500 @@
+package oteltrace
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Statements look like the collector's OTTL:
+//
+//	function(argument, ...) [where condition]
+//
+// Arguments are literals (strings, numbers, true, false, nil and the
+// STATUS_CODE_* and SPAN_KIND_* enums), lists in brackets, paths such as
+// name or attributes["key"], and converter calls such as Concat([a, b], "-").
+// Conditions compare values with ==, !=, <, <=, > and >=, and combine with
+// and, or, not and parentheses.
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenInt
+	tokenFloat
+	tokenPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of statement"
+	}
+	return strconv.Quote(t.text)
+}
+
+func lexStatement(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case isIdentByte(c) && !isDigit(c):
+			j := i
+			for j < len(s) && (isIdentByte(s[j]) || s[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: s[i:j], pos: i})
+			i = j
+		case isDigit(c) || (c == '-' && i+1 < len(s) && isDigit(s[i+1])):
+			j, kind := i+1, tokenInt
+			for j < len(s) && (isDigit(s[j]) || s[j] == '.') {
+				if s[j] == '.' {
+					kind = tokenFloat
+				}
+				j++
+			}
+			tokens = append(tokens, token{kind: kind, text: s[i:j], pos: i})
+			i = j
+		case c == '"':
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("column %d: unterminated string", i+1)
+			}
+			value, err := strconv.Unquote(s[i : j+1])
+			if err != nil {
+				return nil, fmt.Errorf("column %d: invalid string %s: %w", i+1, s[i:j+1], err)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: value, pos: i})
+			i = j + 1
+		default:
+			if i+1 < len(s) {
+				switch op := s[i : i+2]; op {
+				case "==", "!=", "<=", ">=":
+					tokens = append(tokens, token{kind: tokenPunct, text: op, pos: i})
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("()[],<>", rune(c)) {
+				return nil, fmt.Errorf("column %d: unexpected character %q", i+1, c)
+			}
+			tokens = append(tokens, token{kind: tokenPunct, text: string(c), pos: i})
+			i++
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(s)}), nil
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+var transformKeywords = map[string]bool{
+	"where": true,
+	"and":   true,
+	"or":    true,
+	"not":   true,
+	"true":  true,
+	"false": true,
+	"nil":   true,
+}
+
+var transformEnums = map[string]int64{
+	"STATUS_CODE_UNSET":     0,
+	"STATUS_CODE_OK":        1,
+	"STATUS_CODE_ERROR":     2,
+	"SPAN_KIND_UNSPECIFIED": 0,
+	"SPAN_KIND_INTERNAL":    1,
+	"SPAN_KIND_SERVER":      2,
+	"SPAN_KIND_CLIENT":      3,
+	"SPAN_KIND_PRODUCER":    4,
+	"SPAN_KIND_CONSUMER":    5,
+}
+
+// transformExpr is a value of a statement.
+type transformExpr interface {
+	eval(ctx *transformContext) any
+}
+
+type literalExpr struct {
+	value any
+}
+
+func (e literalExpr) eval(*transformContext) any {
+	return e.value
+}
+
+type listExpr []transformExpr
+
+func (e listExpr) eval(ctx *transformContext) any {
+	values := make([]any, 0, len(e))
+	for _, item := range e {
+		values = append(values, item.eval(ctx))
+	}
+	return values
+}
+
+type funcExpr func(ctx *transformContext) any
+
+func (e funcExpr) eval(ctx *transformContext) any {
+	return e(ctx)
+}
+
+// transformCondition is a statement's where clause.
+type transformCondition func(ctx *transformContext) bool
+
+// transformStatement is a parsed statement.
+type transformStatement struct {
+	text      string
+	editor    func(ctx *transformContext) error
+	condition transformCondition
+}
+
+type transformParser struct {
+	tokens  []token
+	i       int
+	context string
+}
+
+func parseTransformStatement(statement, context string) (*transformStatement, error) {
+	tokens, err := lexStatement(statement)
+	if err != nil {
+		return nil, err
+	}
+	p := &transformParser{tokens: tokens, context: context}
+
+	name := p.next()
+	if name.kind != tokenIdent {
+		return nil, p.errorf(name, "expected a function name, got %s", name)
+	}
+	build, ok := transformEditors[name.text]
+	if !ok {
+		return nil, p.errorf(name, "unknown function %q", name.text)
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	args, err := p.parseArgs()
+	if err != nil {
+		return nil, err
+	}
+	editor, err := build(args)
+	if err != nil {
+		return nil, p.errorf(name, "%s: %w", name.text, err)
+	}
+
+	s := &transformStatement{text: statement, editor: editor}
+	if p.peek().kind == tokenIdent && p.peek().text == "where" {
+		p.next()
+		if s.condition, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+	}
+	if tok := p.next(); tok.kind != tokenEOF {
+		return nil, p.errorf(tok, "unexpected %s", tok)
+	}
+	return s, nil
+}
+
+func (p *transformParser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *transformParser) next() token {
+	tok := p.tokens[p.i]
+	if tok.kind != tokenEOF {
+		p.i++
+	}
+	return tok
+}
+
+func (p *transformParser) accept(kind tokenKind, text string) bool {
+	if tok := p.peek(); tok.kind == kind && tok.text == text {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *transformParser) expect(punct string) error {
+	if tok := p.next(); tok.kind != tokenPunct || tok.text != punct {
+		return p.errorf(tok, "expected %q, got %s", punct, tok)
+	}
+	return nil
+}
+
+func (p *transformParser) errorf(tok token, format string, args ...any) error {
+	return fmt.Errorf("column %d: "+format, append([]any{tok.pos + 1}, args...)...)
+}
+
+// parseArgs parses the arguments of a call up to the closing parenthesis.
+func (p *transformParser) parseArgs() ([]transformExpr, error) {
+	var args []transformExpr
+	if p.accept(tokenPunct, ")") {
+		return args, nil
+	}
+	for {
+		arg, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.accept(tokenPunct, ")") {
+			return args, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *transformParser) parseValue() (transformExpr, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenString:
+		return literalExpr{tok.text}, nil
+	case tokenInt:
+		v, err := strconv.ParseInt(tok.text, 10, 64)
+		if err != nil {
+			return nil, p.errorf(tok, "invalid integer %s", tok.text)
+		}
+		return literalExpr{v}, nil
+	case tokenFloat:
+		v, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, p.errorf(tok, "invalid number %s", tok.text)
+		}
+		return literalExpr{v}, nil
+	case tokenPunct:
+		if tok.text != "[" {
+			break
+		}
+		var list listExpr
+		if p.accept(tokenPunct, "]") {
+			return list, nil
+		}
+		for {
+			item, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+			if p.accept(tokenPunct, "]") {
+				return list, nil
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	case tokenIdent:
+		switch tok.text {
+		case "true", "false":
+			return literalExpr{tok.text == "true"}, nil
+		case "nil":
+			return literalExpr{nil}, nil
+		}
+		if transformKeywords[tok.text] {
+			break
+		}
+		if v, ok := transformEnums[tok.text]; ok {
+			return literalExpr{v}, nil
+		}
+		if p.accept(tokenPunct, "(") {
+			return p.parseConverter(tok)
+		}
+		return p.parsePath(tok)
+	}
+	return nil, p.errorf(tok, "expected a value, got %s", tok)
+}
+
+func (p *transformParser) parseConverter(name token) (transformExpr, error) {
+	if !unicode.IsUpper(rune(name.text[0])) {
+		return nil, p.errorf(name, "%s is a function, only converters starting with an upper case letter can be used as values", name.text)
+	}
+	build, ok := transformConverters[name.text]
+	if !ok {
+		return nil, p.errorf(name, "unknown converter %q", name.text)
+	}
+	args, err := p.parseArgs()
+	if err != nil {
+		return nil, err
+	}
+	expr, err := build(args)
+	if err != nil {
+		return nil, p.errorf(name, "%s: %w", name.text, err)
+	}
+	return expr, nil
+}
+
+func (p *transformParser) parsePath(name token) (transformExpr, error) {
+	path, err := resolveTransformPath(p.context, name.text)
+	if err != nil {
+		return nil, p.errorf(name, "%w", err)
+	}
+	if !p.accept(tokenPunct, "[") {
+		return path, nil
+	}
+	key := p.next()
+	if key.kind != tokenString {
+		return nil, p.errorf(key, "expected a string key, got %s", key)
+	}
+	if err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	if path.attributes == nil {
+		return nil, p.errorf(name, "%s is not a map", path.name)
+	}
+	return path.index(key.text), nil
+}
+
+func (p *transformParser) parseOr() (transformCondition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(tokenIdent, "or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(ctx *transformContext) bool { return l(ctx) || right(ctx) }
+	}
+	return left, nil
+}
+
+func (p *transformParser) parseAnd() (transformCondition, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(tokenIdent, "and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(ctx *transformContext) bool { return l(ctx) && right(ctx) }
+	}
+	return left, nil
+}
+
+func (p *transformParser) parseNot() (transformCondition, error) {
+	if p.accept(tokenIdent, "not") {
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx *transformContext) bool { return !inner(ctx) }, nil
+	}
+	if p.accept(tokenPunct, "(") {
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expect(")")
+	}
+
+	left, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	op := p.peek()
+	switch op.text {
+	case "==", "!=", "<", "<=", ">", ">=":
+		if op.kind != tokenPunct {
+			break
+		}
+		p.next()
+		right, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx *transformContext) bool {
+			return compareValues(op.text, left.eval(ctx), right.eval(ctx))
+		}, nil
+	}
+	return func(ctx *transformContext) bool {
+		b, ok := left.eval(ctx).(bool)
+		return ok && b
+	}, nil
+}
+
+// compareValues compares numbers by value whatever their type, and other
+// values only with values of the same type. Attributes decoded from JSON
+// keep integers as ints and everything else as doubles, so ints and floats
+// are both compared as float64 and 500 still equals 500.0.
+func compareValues(op string, a, b any) bool {
+	if af, ok := toFloat(a); ok {
+		if bf, ok := toFloat(b); ok {
+			switch op {
+			case "==":
+				return af == bf
+			case "!=":
+				return af != bf
+			case "<":
+				return af < bf
+			case "<=":
+				return af <= bf
+			case ">":
+				return af > bf
+			case ">=":
+				return af >= bf
+			}
+		}
+	}
+	switch op {
+	case "==":
+		return reflect.DeepEqual(a, b)
+	case "!=":
+		return !reflect.DeepEqual(a, b)
+	}
+	as, aok := a.(string)
+	bs, bok := b.(string)
+	if !aok || !bok {
+		return false
+	}
+	switch op {
+	case "<":
+		return as < bs
+	case "<=":
+		return as <= bs
+	case ">":
+		return as > bs
+	case ">=":
+		return as >= bs
+	}
+	return false
+}
+
+func toFloat(v any) (float64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
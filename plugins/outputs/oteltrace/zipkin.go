@@ -0,0 +1,175 @@
+package oteltrace
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	semconv "go.opentelemetry.io/collector/semconv/v1.16.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// net.peer.ip was dropped from semconv in favour of net.sock.peer.addr, but
+// it's still what most instrumentation sends.
+const attributeNetPeerIP = "net.peer.ip"
+
+// https://zipkin.io/zipkin-api/#/default/post_spans
+type zipkinSpan struct {
+	TraceID        string             `json:"traceId"`
+	ID             string             `json:"id"`
+	ParentID       string             `json:"parentId,omitempty"`
+	Name           string             `json:"name,omitempty"`
+	Kind           string             `json:"kind,omitempty"`
+	Timestamp      int64              `json:"timestamp,omitempty"`
+	Duration       int64              `json:"duration,omitempty"`
+	LocalEndpoint  *zipkinEndpoint    `json:"localEndpoint,omitempty"`
+	RemoteEndpoint *zipkinEndpoint    `json:"remoteEndpoint,omitempty"`
+	Annotations    []zipkinAnnotation `json:"annotations,omitempty"`
+	Tags           map[string]string  `json:"tags,omitempty"`
+}
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName,omitempty"`
+	IPv4        string `json:"ipv4,omitempty"`
+	IPv6        string `json:"ipv6,omitempty"`
+	Port        int64  `json:"port,omitempty"`
+}
+
+type zipkinAnnotation struct {
+	Timestamp int64  `json:"timestamp"`
+	Value     string `json:"value"`
+}
+
+// zipkinExporter posts spans in Zipkin's v2 JSON format.
+type zipkinExporter struct {
+	*httpSender
+}
+
+func newZipkinExporter(c *ExporterConfig) *zipkinExporter {
+	return &zipkinExporter{
+		httpSender: newHTTPSender(c, "/api/v2/spans", "application/json"),
+	}
+}
+
+func (e *zipkinExporter) Export(ctx context.Context, td ptrace.Traces) error {
+	body, err := json.Marshal(tracesToZipkin(td))
+	if err != nil {
+		return fmt.Errorf("failed to marshal zipkin spans: %w", err)
+	}
+	return e.send(ctx, body)
+}
+
+func tracesToZipkin(td ptrace.Traces) []zipkinSpan {
+	zSpans := make([]zipkinSpan, 0, td.SpanCount())
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		localEndpoint := &zipkinEndpoint{}
+		if serviceName, ok := rs.Resource().Attributes().Get(semconv.AttributeServiceName); ok {
+			localEndpoint.ServiceName = serviceName.AsString()
+		}
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			ss := rs.ScopeSpans().At(j)
+			for k := 0; k < ss.Spans().Len(); k++ {
+				zSpan := spanToZipkin(ss.Spans().At(k), rs.Resource(), ss.Scope())
+				zSpan.LocalEndpoint = localEndpoint
+				zSpans = append(zSpans, zSpan)
+			}
+		}
+	}
+	return zSpans
+}
+
+func spanToZipkin(span ptrace.Span, resource pcommon.Resource, scope pcommon.InstrumentationScope) zipkinSpan {
+	zSpan := zipkinSpan{
+		TraceID: span.TraceID().String(),
+		ID:      span.SpanID().String(),
+		Name:    span.Name(),
+		Kind:    zipkinSpanKind(trace.SpanKind(span.Kind())),
+		Tags:    map[string]string{},
+	}
+	if !span.ParentSpanID().IsEmpty() {
+		zSpan.ParentID = span.ParentSpanID().String()
+	}
+	if start := span.StartTimestamp(); start != 0 {
+		zSpan.Timestamp = int64(start) / 1e3
+		if end := span.EndTimestamp(); end > start {
+			zSpan.Duration = int64(end-start) / 1e3
+		}
+	}
+
+	resource.Attributes().Range(func(k string, v pcommon.Value) bool {
+		if k != semconv.AttributeServiceName {
+			zSpan.Tags[k] = v.AsString()
+		}
+		return true
+	})
+	span.Attributes().Range(func(k string, v pcommon.Value) bool {
+		zSpan.Tags[k] = v.AsString()
+		return true
+	})
+	if scope.Name() != "" {
+		zSpan.Tags[semconv.AttributeOtelLibraryName] = scope.Name()
+	}
+	switch span.Status().Code() {
+	case ptrace.StatusCodeError:
+		zSpan.Tags[semconv.AttributeOtelStatusCode] = "ERROR"
+		// Zipkin's UI keys off the error tag
+		zSpan.Tags["error"] = span.Status().Message()
+		if zSpan.Tags["error"] == "" {
+			zSpan.Tags["error"] = "true"
+		}
+	case ptrace.StatusCodeOk:
+		zSpan.Tags[semconv.AttributeOtelStatusCode] = "OK"
+	}
+
+	zSpan.RemoteEndpoint = zipkinRemoteEndpoint(span.Attributes())
+
+	for i := 0; i < span.Events().Len(); i++ {
+		event := span.Events().At(i)
+		value := event.Name()
+		if event.Attributes().Len() > 0 {
+			attributes, err := json.Marshal(event.Attributes().AsRaw())
+			if err == nil {
+				value += "|" + string(attributes)
+			}
+		}
+		zSpan.Annotations = append(zSpan.Annotations, zipkinAnnotation{
+			Timestamp: int64(event.Timestamp()) / 1e3,
+			Value:     value,
+		})
+	}
+	return zSpan
+}
+
+// zipkinRemoteEndpoint builds the remote endpoint from the net.peer.*
+// attributes, returning nil when the span has none.
+func zipkinRemoteEndpoint(attributes pcommon.Map) *zipkinEndpoint {
+	endpoint := &zipkinEndpoint{}
+	if peerService, ok := attributes.Get(semconv.AttributePeerService); ok {
+		endpoint.ServiceName = peerService.AsString()
+	} else if peerName, ok := attributes.Get(semconv.AttributeNetPeerName); ok {
+		endpoint.ServiceName = peerName.AsString()
+	}
+	if peerIP, ok := attributes.Get(attributeNetPeerIP); ok {
+		if ip := net.ParseIP(peerIP.AsString()); ip != nil {
+			if ip.To4() != nil {
+				endpoint.IPv4 = ip.String()
+			} else {
+				endpoint.IPv6 = ip.String()
+			}
+		}
+	}
+	if peerPort, ok := attributes.Get(semconv.AttributeNetPeerPort); ok {
+		if port, err := strconv.ParseInt(peerPort.AsString(), 10, 64); err == nil {
+			endpoint.Port = port
+		}
+	}
+	if *endpoint == (zipkinEndpoint{}) {
+		return nil
+	}
+	return endpoint
+}
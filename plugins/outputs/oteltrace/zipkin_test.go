@@ -0,0 +1,62 @@
+package oteltrace_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/catherinetcai/telegraf-execd-otel/plugins/outputs/oteltrace"
+	influxcommon "github.com/influxdata/influxdb-observability/common"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	semconv "go.opentelemetry.io/collector/semconv/v1.16.0"
+)
+
+func TestOtelTraceZipkin(t *testing.T) {
+	var received []map[string]any
+	zipkin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v2/spans", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(zipkin.Close)
+
+	ot := &oteltrace.OtelTrace{
+		Exporters: []*oteltrace.ExporterConfig{
+			{Name: "zipkin", Type: "zipkin", ServiceAddress: zipkin.URL},
+		},
+		Log: &testutil.Logger{},
+	}
+	require.NoError(t, ot.Init())
+	require.NoError(t, ot.Connect())
+	defer ot.Close()
+
+	m := generateTraceAsMetric()
+	m.AddField(influxcommon.AttributeParentSpanID, "0000000000000001")
+	m.AddField(influxcommon.AttributeEndTimeUnixNano, m.Time().Add(1500*time.Microsecond).UnixNano())
+	m.AddField(semconv.OtelStatusCode, "STATUS_CODE_ERROR")
+	m.AddField(semconv.OtelStatusDescription, "boom")
+	m.AddField(influxcommon.AttributeAttributes, `{"service.name":"frontend","peer.service":"backend","net.peer.ip":"10.0.0.1","net.peer.port":8080,"http.method":"GET"}`)
+	require.NoError(t, ot.Write([]telegraf.Metric{m}))
+
+	require.Len(t, received, 1)
+	span := received[0]
+	assert.Equal(t, "00000000000000000000000000000001", span["traceId"])
+	assert.Equal(t, "000000000000000a", span["id"])
+	assert.Equal(t, "0000000000000001", span["parentId"])
+	assert.Equal(t, "fakespan", span["name"])
+	assert.Equal(t, "SERVER", span["kind"])
+	assert.EqualValues(t, m.Time().UnixMicro(), span["timestamp"])
+	assert.EqualValues(t, 1500, span["duration"])
+	assert.Equal(t, map[string]any{"serviceName": "frontend"}, span["localEndpoint"])
+	assert.Equal(t, map[string]any{"serviceName": "backend", "ipv4": "10.0.0.1", "port": float64(8080)}, span["remoteEndpoint"])
+	tags := span["tags"].(map[string]any)
+	assert.Equal(t, "GET", tags["http.method"])
+	assert.Equal(t, "ERROR", tags["otel.status_code"])
+	assert.Equal(t, "boom", tags["error"])
+}